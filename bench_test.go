@@ -7,18 +7,38 @@ package jsonbench
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
+	"debug/elf"
+	"errors"
 	"flag"
 	"fmt"
+	"go/build"
 	"io"
+	"maps"
+	"math"
+	"math/big"
+	"math/bits"
+	"math/rand/v2"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/iotest"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
 
 	jsonv1 "encoding/json"
 
@@ -78,20 +98,28 @@ var arshalers = []struct {
 	unmarshal     func([]byte, any) error
 	marshalWrite  func(io.Writer, any) error
 	unmarshalRead func(io.Reader, any) error
+
+	// unmarshalStrict is like unmarshal, but if strict is specified,
+	// rejects JSON object members that do not match any Go struct field.
+	// Both modes use the same entry point (e.g., a Decoder for v1-like APIs)
+	// so that they only differ in the cost of rejecting unknown members.
+	unmarshalStrict func(b []byte, v any, strict bool) error
 }{{
-	name:          "JSONv1",
-	pkgPath:       "encoding/json",
-	marshal:       jsonv1.Marshal,
-	unmarshal:     jsonv1.Unmarshal,
-	marshalWrite:  func(w io.Writer, v any) error { return jsonv1.NewEncoder(w).Encode(v) },
-	unmarshalRead: func(r io.Reader, v any) error { return jsonv1.NewDecoder(r).Decode(v) },
+	name:            "JSONv1",
+	pkgPath:         "encoding/json",
+	marshal:         jsonv1.Marshal,
+	unmarshal:       jsonv1.Unmarshal,
+	marshalWrite:    func(w io.Writer, v any) error { return jsonv1.NewEncoder(w).Encode(v) },
+	unmarshalRead:   func(r io.Reader, v any) error { return jsonv1.NewDecoder(r).Decode(v) },
+	unmarshalStrict: strictWithDecoder(jsonv1.NewDecoder, (*jsonv1.Decoder).DisallowUnknownFields),
 }, {
-	name:          "JSONv1in2",
-	pkgPath:       "github.com/go-json-experiment/json/v1",
-	marshal:       jsonv1in2.Marshal,
-	unmarshal:     jsonv1in2.Unmarshal,
-	marshalWrite:  func(w io.Writer, v any) error { return jsonv1in2.NewEncoder(w).Encode(v) },
-	unmarshalRead: func(r io.Reader, v any) error { return jsonv1in2.NewDecoder(r).Decode(v) },
+	name:            "JSONv1in2",
+	pkgPath:         "github.com/go-json-experiment/json/v1",
+	marshal:         jsonv1in2.Marshal,
+	unmarshal:       jsonv1in2.Unmarshal,
+	marshalWrite:    func(w io.Writer, v any) error { return jsonv1in2.NewEncoder(w).Encode(v) },
+	unmarshalRead:   func(r io.Reader, v any) error { return jsonv1in2.NewDecoder(r).Decode(v) },
+	unmarshalStrict: strictWithDecoder(jsonv1in2.NewDecoder, (*jsonv1in2.Decoder).DisallowUnknownFields),
 }, {
 	name:          "JSONv2",
 	pkgPath:       "github.com/go-json-experiment/json",
@@ -99,43 +127,488 @@ var arshalers = []struct {
 	unmarshal:     func(b []byte, v any) error { return jsonv2.Unmarshal(b, v) },
 	marshalWrite:  func(w io.Writer, v any) error { return jsonv2.MarshalWrite(w, v) },
 	unmarshalRead: func(r io.Reader, v any) error { return jsonv2.UnmarshalRead(r, v) },
+	unmarshalStrict: func(b []byte, v any, strict bool) error {
+		return jsonv2.Unmarshal(b, v, jsonv2.RejectUnknownMembers(strict))
+	},
 }, {
-	name:          "JSONIterator",
-	pkgPath:       "github.com/json-iterator/go",
-	marshal:       jsoniter.Marshal,
-	unmarshal:     jsoniter.Unmarshal,
-	marshalWrite:  func(w io.Writer, v any) error { return jsoniter.NewEncoder(w).Encode(v) },
-	unmarshalRead: func(r io.Reader, v any) error { return jsoniter.NewDecoder(r).Decode(v) },
+	name:            "JSONIterator",
+	pkgPath:         "github.com/json-iterator/go",
+	marshal:         jsoniter.Marshal,
+	unmarshal:       jsoniter.Unmarshal,
+	marshalWrite:    func(w io.Writer, v any) error { return jsoniter.NewEncoder(w).Encode(v) },
+	unmarshalRead:   func(r io.Reader, v any) error { return jsoniter.NewDecoder(r).Decode(v) },
+	unmarshalStrict: unmarshalWithConfig(jsoniter.Config{EscapeHTML: true}.Froze().Unmarshal, jsoniter.Config{EscapeHTML: true, DisallowUnknownFields: true}.Froze().Unmarshal),
 }, {
-	name:          "SegmentJSON",
-	pkgPath:       "github.com/segmentio/encoding/json",
-	marshal:       segjson.Marshal,
-	unmarshal:     segjson.Unmarshal,
-	marshalWrite:  func(w io.Writer, v any) error { return segjson.NewEncoder(w).Encode(v) },
-	unmarshalRead: func(r io.Reader, v any) error { return segjson.NewDecoder(r).Decode(v) },
+	name:            "SegmentJSON",
+	pkgPath:         "github.com/segmentio/encoding/json",
+	marshal:         segjson.Marshal,
+	unmarshal:       segjson.Unmarshal,
+	marshalWrite:    func(w io.Writer, v any) error { return segjson.NewEncoder(w).Encode(v) },
+	unmarshalRead:   func(r io.Reader, v any) error { return segjson.NewDecoder(r).Decode(v) },
+	unmarshalStrict: strictWithDecoder(segjson.NewDecoder, (*segjson.Decoder).DisallowUnknownFields),
 }, {
-	name:          "GoJSON",
-	pkgPath:       "github.com/goccy/go-json",
-	marshal:       gojson.Marshal,
-	unmarshal:     gojson.Unmarshal,
-	marshalWrite:  func(w io.Writer, v any) error { return gojson.NewEncoder(w).Encode(v) },
-	unmarshalRead: func(r io.Reader, v any) error { return gojson.NewDecoder(r).Decode(v) },
+	name:            "GoJSON",
+	pkgPath:         "github.com/goccy/go-json",
+	marshal:         gojson.Marshal,
+	unmarshal:       gojson.Unmarshal,
+	marshalWrite:    func(w io.Writer, v any) error { return gojson.NewEncoder(w).Encode(v) },
+	unmarshalRead:   func(r io.Reader, v any) error { return gojson.NewDecoder(r).Decode(v) },
+	unmarshalStrict: strictWithDecoder(gojson.NewDecoder, (*gojson.Decoder).DisallowUnknownFields),
 }, {
-	name:          "SonicJSON",
-	pkgPath:       "github.com/bytedance/sonic",
-	marshal:       sonicjson.Marshal,
-	unmarshal:     sonicjson.Unmarshal,
-	marshalWrite:  func(w io.Writer, v any) error { return sonicenc.NewStreamEncoder(w).Encode(v) },
-	unmarshalRead: func(r io.Reader, v any) error { return sonicdec.NewStreamDecoder(r).Decode(v) },
+	name:            "SonicJSON",
+	pkgPath:         "github.com/bytedance/sonic",
+	marshal:         sonicjson.Marshal,
+	unmarshal:       sonicjson.Unmarshal,
+	marshalWrite:    func(w io.Writer, v any) error { return sonicenc.NewStreamEncoder(w).Encode(v) },
+	unmarshalRead:   func(r io.Reader, v any) error { return sonicdec.NewStreamDecoder(r).Decode(v) },
+	unmarshalStrict: unmarshalWithConfig(sonicjson.Config{}.Froze().Unmarshal, sonicjson.Config{DisallowUnknownFields: true}.Froze().Unmarshal),
 }, {
-	name:          "SonnetJSON",
-	pkgPath:       "github.com/sugawarayuuta/sonnet",
-	marshal:       sonnetjson.Marshal,
-	unmarshal:     sonnetjson.Unmarshal,
-	marshalWrite:  func(w io.Writer, v any) error { return sonnetjson.NewEncoder(w).Encode(v) },
-	unmarshalRead: func(r io.Reader, v any) error { return sonnetjson.NewDecoder(r).Decode(v) },
+	name:            "SonnetJSON",
+	pkgPath:         "github.com/sugawarayuuta/sonnet",
+	marshal:         sonnetjson.Marshal,
+	unmarshal:       sonnetjson.Unmarshal,
+	marshalWrite:    func(w io.Writer, v any) error { return sonnetjson.NewEncoder(w).Encode(v) },
+	unmarshalRead:   func(r io.Reader, v any) error { return sonnetjson.NewDecoder(r).Decode(v) },
+	unmarshalStrict: strictWithDecoder(sonnetjson.NewDecoder, (*sonnetjson.Decoder).DisallowUnknownFields),
 }}
 
+// unmarshalWithDecoder constructs an unmarshal function from
+// a v1-like Decoder API, where configure is called on each Decoder.
+func unmarshalWithDecoder[D interface{ Decode(any) error }](newDecoder func(io.Reader) D, configure func(D)) func([]byte, any) error {
+	return func(b []byte, v any) error {
+		d := newDecoder(bytes.NewReader(b))
+		configure(d)
+		return d.Decode(v)
+	}
+}
+
+// strictWithDecoder constructs a strict unmarshal function from
+// a v1-like Decoder API, where strict is called on each Decoder in strict mode.
+func strictWithDecoder[D interface{ Decode(any) error }](newDecoder func(io.Reader) D, strict func(D)) func([]byte, any, bool) error {
+	return func(b []byte, v any, isStrict bool) error {
+		d := newDecoder(bytes.NewReader(b))
+		if isStrict {
+			strict(d)
+		}
+		return d.Decode(v)
+	}
+}
+
+// unmarshalWithConfig constructs a strict unmarshal function from
+// the unmarshal functions of a default and a strict configuration.
+func unmarshalWithConfig(nonStrict, strict func([]byte, any) error) func([]byte, any, bool) error {
+	return func(b []byte, v any, isStrict bool) error {
+		if isStrict {
+			return strict(b, v)
+		}
+		return nonStrict(b, v)
+	}
+}
+
+var (
+	isolate        = flag.Bool("isolate", false, "run the tests for each JSON implementation in a separate child process")
+	isolateTimeout = flag.Duration("isolate-timeout", 10*time.Minute, "timeout for each child process when running with --isolate")
+	stress         = flag.Int("stress", 0, "run the tests for each JSON implementation this many times in separate child processes (implies --isolate) under high parallelism and GC pressure")
+	profileDir     = flag.String("profile-dir", "", "run each benchmark in a separate child process and write its CPU and allocation profiles into this directory")
+)
+
+// isolateChildEnv is the environment variable that specifies
+// the only JSON implementation to test within a child process.
+const isolateChildEnv = "JSONBENCH_ISOLATE_IMPL"
+
+// isolatedImpl is the name of the only JSON implementation being tested
+// if running within a child process in isolation mode.
+var isolatedImpl = os.Getenv(isolateChildEnv)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if isolatedImpl != "" {
+		var only []int
+		for i, a := range arshalers {
+			if a.name == isolatedImpl {
+				only = append(only, i)
+			}
+		}
+		if len(only) != 1 {
+			fmt.Fprintf(os.Stderr, "unknown implementation: %s\n", isolatedImpl)
+			os.Exit(2)
+		}
+		arshalers = arshalers[only[0] : only[0]+1]
+	} else if *stress > 0 {
+		os.Exit(runStress())
+	} else if *isolate {
+		os.Exit(runIsolated())
+	} else if *profileDir != "" {
+		os.Exit(runProfiles())
+	}
+	os.Exit(m.Run())
+}
+
+// childTest is the result of a top-level test run in a child process.
+type childTest struct {
+	name   string
+	result string // either "ok", "FAIL", "skip", or "crashed"
+	output string // excludes "=== " lines unless verbose
+}
+
+// childArgs returns the command-line flags to pass through to a child process,
+// excluding those that only apply to the parent and -test.skip,
+// which is returned separately.
+func childArgs() (args []string, skip string) {
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "isolate", "isolate-timeout", "stress", "profile-dir", "test.v":
+		case "test.skip":
+			skip = f.Value.String()
+		default:
+			args = append(args, "-"+f.Name+"="+f.Value.String())
+		}
+	})
+	return args, skip
+}
+
+// runChild runs the tests for the named implementation in a child process
+// with the provided arguments and additional environment variables.
+// Benchmark results are printed as they are reported by the child.
+// If the child crashes or times out, the running test is reported last
+// with a "crashed" result. A non-nil error without a crashed test means
+// that the child failed outside of any test.
+func runChild(impl string, args []string, env ...string) ([]childTest, []byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), *isolateTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, must.Get(os.Executable()), append(args, "-test.v=true")...)
+	cmd.Env = append(append(os.Environ(), isolateChildEnv+"="+impl), env...)
+	out, err := cmd.CombinedOutput()
+
+	// Parse the results of each top-level test.
+	var tests []childTest
+	var running string
+	var lines []string
+	for _, line := range strings.SplitAfter(string(out), "\n") {
+		switch {
+		case strings.HasPrefix(line, "=== RUN   ") && !strings.Contains(line, "/"):
+			running, lines = strings.TrimSpace(strings.TrimPrefix(line, "=== RUN   ")), nil
+		case benchmarkLineRx.MatchString(line):
+			fmt.Print(line)
+		}
+		if testing.Verbose() || !strings.HasPrefix(line, "=== ") {
+			lines = append(lines, line)
+		}
+		for prefix, result := range map[string]string{"--- PASS: ": "ok", "--- FAIL: ": "FAIL", "--- SKIP: ": "skip"} {
+			if name, _, ok := strings.Cut(strings.TrimPrefix(line, prefix), " "); strings.HasPrefix(line, prefix) && ok && name == running {
+				tests = append(tests, childTest{running, result, strings.Join(lines, "")})
+				running, lines = "", nil
+			}
+		}
+	}
+	if err != nil && running != "" {
+		if ctx.Err() != nil {
+			lines = append(lines, fmt.Sprintf("timed out after %v\n", *isolateTimeout))
+		}
+		tests = append(tests, childTest{running, "crashed", strings.Join(lines, "")})
+	}
+	return tests, out, err
+}
+
+// runIsolated runs the tests for each implementation in a separate
+// child process and prints a summary of the results for each test.
+// If a child process crashes or times out, the running test is recorded
+// as having crashed and the child process is restarted without that test.
+func runIsolated() int {
+	args, userSkip := childArgs()
+	var tests []string
+	results := make(map[string]map[string]string) // test name -> implementation -> result
+	record := func(test, impl, result string) {
+		if results[test] == nil {
+			tests = append(tests, test)
+			results[test] = make(map[string]string)
+		}
+		results[test][impl] = result
+	}
+	for _, a := range arshalers {
+		var skipped []string
+		for {
+			// The testing package splits a pattern into top-level alternatives
+			// before splitting each alternative on "/" into per-level patterns,
+			// so the crashed tests are skipped by adding a separate alternative
+			// with a single level, which leaves any levels in userSkip intact.
+			skip := userSkip
+			if len(skipped) > 0 {
+				skipAlt := "^(" + strings.Join(skipped, "|") + ")$"
+				if userSkip != "" {
+					skipAlt = "|" + skipAlt
+				}
+				skip += skipAlt
+			}
+			ran, out, err := runChild(a.name, append(args, "-test.skip="+skip))
+
+			// Only print the output of tests that did not pass unless verbose.
+			var crashed string
+			for _, t := range ran {
+				record(t.name, a.name, t.result)
+				if t.result != "ok" && t.result != "skip" || testing.Verbose() {
+					fmt.Printf("%s:\n%s", a.name, t.output)
+				}
+				if t.result == "crashed" {
+					crashed = t.name
+				}
+			}
+			if err != nil && crashed == "" {
+				fmt.Printf("%s:\n%s", a.name, out) // failed outside of any test
+			}
+			if crashed == "" {
+				break
+			}
+			skipped = append(skipped, regexp.QuoteMeta(crashed))
+		}
+	}
+
+	// Print a summary table of the results.
+	failed := false
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprint(tw, "Test")
+	for _, a := range arshalers {
+		fmt.Fprint(tw, "\t"+a.name)
+	}
+	fmt.Fprintln(tw)
+	for _, test := range tests {
+		fmt.Fprint(tw, test)
+		for _, a := range arshalers {
+			result := results[test][a.name]
+			if result == "" {
+				result = "-" // never ran
+			}
+			failed = failed || result == "FAIL" || result == "crashed"
+			fmt.Fprint(tw, "\t"+result)
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+	if failed {
+		fmt.Println("FAIL")
+		return 1
+	}
+	fmt.Println("PASS")
+	return 0
+}
+
+// runStress repeatedly runs the tests for each implementation in a separate
+// child process and prints the rate of failed and crashed runs,
+// along with the distinct failure signatures for each implementation.
+// To shake out latent races and memory corruption, each child runs
+// with many parallel tests and GOGC=1 (unless GOGC is already set).
+// Unlike runIsolated, a crashed run is not restarted.
+func runStress() int {
+	args, skip := childArgs()
+	args = append(args, fmt.Sprintf("-test.parallel=%d", 8*runtime.GOMAXPROCS(0)))
+	if skip != "" {
+		args = append(args, "-test.skip="+skip)
+	}
+	var env []string
+	if os.Getenv("GOGC") == "" {
+		env = append(env, "GOGC=1")
+	}
+
+	type stressResult struct {
+		failed, crashed int
+		signatures      []string       // in order of first occurrence
+		counts          map[string]int // signature -> number of runs
+	}
+	results := make(map[string]*stressResult)
+	for _, a := range arshalers {
+		r := &stressResult{counts: make(map[string]int)}
+		results[a.name] = r
+		for i := range *stress {
+			ran, out, err := runChild(a.name, args, env...)
+			seen := make(map[string]bool)
+			result := "ok"
+			for _, t := range ran {
+				if t.result == "FAIL" || t.result == "crashed" {
+					seen[t.name+": "+failureSignature(t.output)] = true
+					if result != "crashed" {
+						result = t.result
+					}
+				}
+			}
+			if err != nil && result == "ok" {
+				seen["exited: "+failureSignature(string(out))] = true
+				result = "crashed"
+			}
+			for sig := range seen {
+				if r.counts[sig] == 0 {
+					r.signatures = append(r.signatures, sig)
+				}
+				r.counts[sig]++
+			}
+			switch result {
+			case "FAIL":
+				r.failed++
+			case "crashed":
+				r.crashed++
+			}
+			if testing.Verbose() {
+				fmt.Printf("%s: run %d/%d: %s\n", a.name, i+1, *stress, result)
+			}
+		}
+	}
+
+	// Print a summary table of the failure rates and signatures.
+	failed := false
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Implementation\tRuns\tFailed\tCrashed\tFailure rate")
+	for _, a := range arshalers {
+		r := results[a.name]
+		failed = failed || r.failed+r.crashed > 0
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%.0f%%\n", a.name, *stress, r.failed, r.crashed, 100*float64(r.failed+r.crashed)/float64(*stress))
+	}
+	tw.Flush()
+	for _, a := range arshalers {
+		r := results[a.name]
+		if len(r.signatures) > 0 {
+			fmt.Printf("\n%s:\n", a.name)
+			for _, sig := range r.signatures {
+				fmt.Printf("\t%d/%d\t%s\n", r.counts[sig], *stress, sig)
+			}
+		}
+	}
+	if failed {
+		fmt.Println("FAIL")
+		return 1
+	}
+	fmt.Println("PASS")
+	return 0
+}
+
+var (
+	sourceLineRx = regexp.MustCompile(`^\w+\.go:\d+: `)
+	hexNumberRx  = regexp.MustCompile(`0x[0-9a-fA-F]+`)
+	decNumberRx  = regexp.MustCompile(`[0-9]+`)
+)
+
+// failureSignature reduces the output of a failed test or process
+// to a single line, with addresses and numbers elided,
+// such that equivalent failures across runs have the same signature.
+func failureSignature(output string) string {
+	if strings.Contains(output, "timed out after ") {
+		return "timed out"
+	}
+	sig := crashRx.FindString(output)
+	if sig == "" {
+		for _, line := range strings.Split(output, "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "--- ") {
+				sig = sourceLineRx.ReplaceAllString(line, "")
+				break
+			}
+		}
+	}
+	sig = hexNumberRx.ReplaceAllString(sig, "0x?")
+	sig = decNumberRx.ReplaceAllString(sig, "N")
+	if utf8.RuneCountInString(sig) > 120 {
+		sig = string([]rune(sig)[:120]) + "…"
+	}
+	return sig
+}
+
+// runProfiles runs each benchmark in a separate child process
+// and writes its CPU and allocation profiles into *profileDir,
+// along with a summary of the top functions by flat CPU time
+// and the top allocation sites by allocated bytes for each benchmark.
+// Only benchmarks that match -test.bench (if specified) are run.
+// The benchmarks are found by running each of them once beforehand,
+// so that every sub-benchmark is profiled on its own.
+func runProfiles() int {
+	dir := must.Get(filepath.Abs(*profileDir))
+	must.Do(os.MkdirAll(dir, 0775))
+	userArgs, skip := childArgs()
+	if skip != "" {
+		userArgs = append(userArgs, "-test.skip="+skip)
+	}
+	// Children only report the metrics that do not perturb the profiles.
+	args := []string{"-profile-dir=" + dir}
+	bench := "."
+	for _, arg := range userArgs {
+		switch name, value, _ := strings.Cut(strings.TrimPrefix(arg, "-"), "="); name {
+		case "test.run", "test.cpuprofile", "test.memprofile":
+		case "test.bench":
+			bench = value
+		default:
+			args = append(args, arg)
+		}
+	}
+	topTable := func(path string, flags ...string) string {
+		out, err := exec.Command("go", append(append([]string{"tool", "pprof", "-top", "-nodecount=10"}, flags...), path)...).CombinedOutput()
+		if err != nil {
+			return fmt.Sprintf("go tool pprof error: %v\n%s", err, out)
+		}
+		// Skip the header to only keep the table itself.
+		if i := bytes.Index(out, []byte("      flat  flat%")); i >= 0 {
+			out = out[i:]
+		}
+		return string(out)
+	}
+
+	var summary bytes.Buffer
+	status := 0
+	for _, a := range arshalers {
+		names, err := listBenchmarks(a.name, append(args, "-test.bench="+bench))
+		if err != nil {
+			fmt.Printf("%s: %v\n", a.name, err)
+			status = 1
+			continue
+		}
+		for _, name := range names {
+			var pattern []string
+			for _, s := range strings.Split(name, "/") {
+				pattern = append(pattern, "^"+regexp.QuoteMeta(s)+"$")
+			}
+			base := filepath.Join(dir, strings.ReplaceAll(strings.TrimPrefix(name, "Benchmark/"), "/", "-"))
+			cpuProfile, memProfile := base+".cpu.pprof", base+".mem.pprof"
+			_, out, err := runChild(a.name, append(args,
+				"-test.run=^$",
+				"-test.bench="+strings.Join(pattern, "/"),
+				"-test.cpuprofile="+cpuProfile,
+				"-test.memprofile="+memProfile,
+			))
+			if err != nil {
+				fmt.Printf("%s: %v\n%s", name, err, out)
+				status = 1
+				continue
+			}
+			fmt.Fprintf(&summary, "# %s\n\n", strings.TrimPrefix(name, "Benchmark/"))
+			fmt.Fprintf(&summary, "Top functions by CPU time:\n%s\n", topTable(cpuProfile))
+			fmt.Fprintf(&summary, "Top allocation sites by bytes:\n%s\n", topTable(memProfile, "-sample_index=alloc_space", "-lines"))
+		}
+	}
+	must.Do(os.WriteFile(filepath.Join(dir, "summary.txt"), summary.Bytes(), 0664))
+	fmt.Printf("wrote profiles and summary to %s\n", dir)
+	return status
+}
+
+// benchmarkLineRx matches the name of a benchmark in a line of benchmark results,
+// excluding the -GOMAXPROCS suffix.
+var benchmarkLineRx = regexp.MustCompile(`^(Benchmark\S*?)(?:-\d+)?\s+\d+\s`)
+
+// listBenchmarks lists the names of the benchmarks for the named implementation
+// that match the provided arguments by running each of them once in a child process.
+func listBenchmarks(impl string, args []string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), *isolateTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, must.Get(os.Executable()), append(args, "-test.run=^$", "-test.benchtime=1x")...)
+	cmd.Env = append(os.Environ(), isolateChildEnv+"="+impl)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%v\n%s", err, out)
+	}
+	var names []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if m := benchmarkLineRx.FindStringSubmatch(line); m != nil {
+			names = append(names, m[1])
+		}
+	}
+	return names, nil
+}
+
 func TestRoundtrip(t *testing.T) {
 	for _, td := range testdata {
 		td := td
@@ -218,260 +691,2705 @@ func TestRoundtrip(t *testing.T) {
 	}
 }
 
-// TestStreaming tests whether the implementation is truly streaming,
-// meaning that encoding and decoding should not allocate any buffers
-// as large as the entire JSON value.
-func TestStreaming(t *testing.T) {
-	wantStreaming := map[string]bool{
-		"JSONv1/Marshal":         false,
-		"JSONv1/Unmarshal":       false,
-		"JSONv1in2/Marshal":      false,
-		"JSONv1in2/Unmarshal":    false,
-		"JSONv2/Marshal":         true,
-		"JSONv2/Unmarshal":       true,
-		"JSONIterator/Marshal":   false,
-		"JSONIterator/Unmarshal": true,
-		"SegmentJSON/Marshal":    false,
-		"SegmentJSON/Unmarshal":  false,
-		"GoJSON/Marshal":         false,
-		"GoJSON/Unmarshal":       false,
-		"SonicJSON/Marshal":      false,
-		"SonicJSON/Unmarshal":    false,
-		"SonnetJSON/Marshal":     false,
-		"SonnetJSON/Unmarshal":   false,
-	}
-
-	const size = 1e6
-	value := "[" + strings.TrimSuffix(strings.Repeat("{},", size), ",") + "]"
-	for _, a := range arshalers {
-		for _, funcName := range []string{"Marshal", "Unmarshal"} {
-			name := fmt.Sprintf("%s/%s", a.name, funcName)
-			t.Run(name, func(t *testing.T) {
-				// Run GC multiple times to fully clear any sync.Pools.
-				for i := 0; i < 10; i++ {
-					runtime.GC()
-				}
-
-				// Measure allocations beforehand.
-				var statsBefore runtime.MemStats
-				runtime.ReadMemStats(&statsBefore)
-
-				// Streaming marshal/unmarshal a large JSON array.
-				switch funcName {
-				case "Marshal":
-					in := make([]struct{}, size)
-					out := io.Discard
-					must.Do(a.marshalWrite(out, &in))
-				case "Unmarshal":
-					in := strings.NewReader(value)
-					out := make([]struct{}, 0, size)
-					must.Do(a.unmarshalRead(in, &out))
-				}
-
-				// Measure allocations afterwards.
-				var statsAfter runtime.MemStats
-				runtime.ReadMemStats(&statsAfter)
-
-				// True streaming implementations only use a small fixed buffer.
-				allocBytes := statsAfter.TotalAlloc - statsBefore.TotalAlloc
-				allocObjects := statsAfter.Mallocs - statsBefore.Mallocs
-				gotStreaming := allocBytes < 1<<16
-				if gotStreaming != wantStreaming[name] {
-					t.Errorf("streaming = %v, want %v", gotStreaming, wantStreaming[a.name])
-				}
-				t.Logf("%d bytes allocated, %d objects allocted", allocBytes, allocObjects)
-			})
+var (
+	randomTypes                   = flag.Int("random-types", 100, "number of random Go types to check in TestRandomRoundtrip")
+	updateRandomRoundtripResults  = flag.Bool("update-random-roundtrip-results", false, "update the results from running the random roundtrip test")
+	randomRoundtripChildEnv       = "JSONBENCH_RANDOM_ROUNDTRIP"
+	randomRoundtripChildCheckTag  = "random-roundtrip-check: "
+	randomRoundtripChildResultTag = "random-roundtrip-result: "
+	randomRoundtripChildDoneTag   = "random-roundtrip-done"
+)
+
+// randomType is a randomly generated Go type,
+// represented in a form that is easy to shrink.
+type randomType struct {
+	kind   reflect.Kind  // Bool, Int, Int64, Uint8, Float64, String, Pointer, Slice, Map, or Struct
+	key    reflect.Kind  // String or Int key for a Map
+	elem   *randomType   // element type for a Pointer, Slice, or Map
+	fields []randomField // fields for a Struct
+}
+
+type randomField struct {
+	tag string // "", "renamed", "omitempty", "string", or "ignored"
+	typ *randomType
+}
+
+func (rt *randomType) isScalar() bool {
+	switch rt.kind {
+	case reflect.Bool, reflect.Int, reflect.Int64, reflect.Uint8, reflect.Float64, reflect.String:
+		return true
+	default:
+		return false
+	}
+}
+
+func newRandomType(r *rand.Rand, depth int) *randomType {
+	scalars := []reflect.Kind{reflect.Bool, reflect.Int, reflect.Int64, reflect.Uint8, reflect.Float64, reflect.String}
+	composites := []reflect.Kind{reflect.Pointer, reflect.Slice, reflect.Map, reflect.Struct, reflect.Struct}
+	if depth >= 3 || r.IntN(3) == 0 {
+		return &randomType{kind: scalars[r.IntN(len(scalars))]}
+	}
+	rt := &randomType{kind: composites[r.IntN(len(composites))]}
+	switch rt.kind {
+	case reflect.Pointer, reflect.Slice:
+		rt.elem = newRandomType(r, depth+1)
+	case reflect.Map:
+		rt.key = []reflect.Kind{reflect.String, reflect.Int}[r.IntN(2)]
+		rt.elem = newRandomType(r, depth+1)
+	case reflect.Struct:
+		for range 1 + r.IntN(4) {
+			f := randomField{typ: newRandomType(r, depth+1)}
+			tags := []string{"", "renamed", "omitempty", "ignored"}
+			if f.typ.isScalar() {
+				tags = append(tags, "string")
+			}
+			f.tag = tags[r.IntN(len(tags))]
+			rt.fields = append(rt.fields, f)
 		}
 	}
+	return rt
 }
 
-// Per RFC 8259, section 8.1, JSON text must be encoded using UTF-8.
-func TestValidateUTF8(t *testing.T) {
-	type mode string
+func (rt *randomType) reflectType() reflect.Type {
+	switch rt.kind {
+	case reflect.Pointer:
+		return reflect.PointerTo(rt.elem.reflectType())
+	case reflect.Slice:
+		return reflect.SliceOf(rt.elem.reflectType())
+	case reflect.Map:
+		return reflect.MapOf(map[reflect.Kind]reflect.Type{
+			reflect.String: reflect.TypeFor[string](),
+			reflect.Int:    reflect.TypeFor[int](),
+		}[rt.key], rt.elem.reflectType())
+	case reflect.Struct:
+		var fields []reflect.StructField
+		for i, f := range rt.fields {
+			name := fmt.Sprintf("F%d", i)
+			tag := map[string]string{
+				"":          "",
+				"renamed":   fmt.Sprintf(`json:"renamed%d"`, i),
+				"omitempty": `json:",omitempty"`,
+				"string":    `json:",string"`,
+				"ignored":   `json:"-"`,
+			}[f.tag]
+			fields = append(fields, reflect.StructField{Name: name, Type: f.typ.reflectType(), Tag: reflect.StructTag(tag)})
+		}
+		return reflect.StructOf(fields)
+	default:
+		return map[reflect.Kind]reflect.Type{
+			reflect.Bool:    reflect.TypeFor[bool](),
+			reflect.Int:     reflect.TypeFor[int](),
+			reflect.Int64:   reflect.TypeFor[int64](),
+			reflect.Uint8:   reflect.TypeFor[uint8](),
+			reflect.Float64: reflect.TypeFor[float64](),
+			reflect.String:  reflect.TypeFor[string](),
+		}[rt.kind]
+	}
+}
+
+// shrink returns a list of types that are strictly simpler than rt.
+func (rt *randomType) shrink() (out []*randomType) {
+	if rt.kind != reflect.Bool {
+		out = append(out, &randomType{kind: reflect.Bool})
+	}
+	switch rt.kind {
+	case reflect.Pointer, reflect.Slice, reflect.Map:
+		out = append(out, rt.elem)
+		for _, elem := range rt.elem.shrink() {
+			out = append(out, &randomType{kind: rt.kind, key: rt.key, elem: elem})
+		}
+	case reflect.Struct:
+		for i, f := range rt.fields {
+			out = append(out, f.typ)
+			withFields := func(f randomField) *randomType {
+				fields := slices.Clone(rt.fields)
+				fields[i] = f
+				return &randomType{kind: rt.kind, fields: fields}
+			}
+			if len(rt.fields) > 1 {
+				out = append(out, &randomType{kind: rt.kind, fields: slices.Delete(slices.Clone(rt.fields), i, i+1)})
+			}
+			if f.tag != "" {
+				out = append(out, withFields(randomField{typ: f.typ}))
+			}
+			for _, typ := range f.typ.shrink() {
+				if f.tag != "string" || typ.isScalar() {
+					out = append(out, withFields(randomField{tag: f.tag, typ: typ}))
+				}
+			}
+		}
+	}
+	return out
+}
+
+// fillRandomValue populates v with random data.
+func fillRandomValue(r *rand.Rand, v reflect.Value) {
+	stringValues := []string{"", "hello", "Hello, 世界", "<&>", "\"\\\n\t", "  ", "\U0001f600", "\x7f"}
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(r.IntN(2) == 0)
+	case reflect.Int, reflect.Int64:
+		v.SetInt([]int64{0, 1, -1, math.MaxInt64, math.MinInt64, 1<<53 + 1, r.Int64N(1000)}[r.IntN(7)])
+	case reflect.Uint8:
+		v.SetUint(uint64(r.IntN(256)))
+	case reflect.Float64:
+		v.SetFloat([]float64{0, 1, -1.5, 1e21, 1e-7, math.MaxFloat64, math.SmallestNonzeroFloat64, r.NormFloat64()}[r.IntN(8)])
+	case reflect.String:
+		v.SetString(stringValues[r.IntN(len(stringValues))])
+	case reflect.Pointer:
+		if r.IntN(4) > 0 {
+			v.Set(reflect.New(v.Type().Elem()))
+			fillRandomValue(r, v.Elem())
+		}
+	case reflect.Slice:
+		if r.IntN(4) > 0 {
+			v.Set(reflect.MakeSlice(v.Type(), r.IntN(3), r.IntN(3)+3))
+			for i := range v.Len() {
+				fillRandomValue(r, v.Index(i))
+			}
+		}
+	case reflect.Map:
+		if r.IntN(4) > 0 {
+			v.Set(reflect.MakeMap(v.Type()))
+			for range r.IntN(3) {
+				k := reflect.New(v.Type().Key()).Elem()
+				e := reflect.New(v.Type().Elem()).Elem()
+				fillRandomValue(r, k)
+				fillRandomValue(r, e)
+				v.SetMapIndex(k, e)
+			}
+		}
+	case reflect.Struct:
+		for i := range v.NumField() {
+			fillRandomValue(r, v.Field(i))
+		}
+	}
+}
+
+// TestRandomRoundtrip checks that randomly generated Go types and values
+// roundtrip through each implementation the same way they do through JSONv1.
+// Each failure is shrunk to a minimal Go type.
+// Since an implementation may crash, each implementation is tested
+// within a child process. After a crash, the child process is restarted
+// to continue shrinking from the crashing type, so that crashes are
+// shrunk to a minimal type the same as any other failure (including panics).
+func TestRandomRoundtrip(t *testing.T) {
 	const (
-		ignored  mode = "ignored"  // invalid UTF-8 is ignored
-		replaced mode = "replaced" // invalid UTF-8 is replaced with utf8.RuneError
-		rejected mode = "rejected" // invalid UTF-8 is rejected
+		numValues       = 4  // number of random values to check per type
+		numShrinkValues = 32 // number of random values to check per shrunk type
 	)
-	wantModes := map[string]mode{
-		"JSONv1/Marshal":         replaced,
-		"JSONv1/Unmarshal":       replaced,
-		"JSONv1in2/Marshal":      replaced,
-		"JSONv1in2/Unmarshal":    replaced,
-		"JSONv2/Marshal":         rejected,
-		"JSONv2/Unmarshal":       rejected,
-		"JSONIterator/Marshal":   replaced,
-		"JSONIterator/Unmarshal": ignored,
-		"SegmentJSON/Marshal":    replaced,
-		"SegmentJSON/Unmarshal":  replaced,
-		"GoJSON/Marshal":         replaced,
-		"GoJSON/Unmarshal":       ignored,
-		"SonicJSON/Marshal":      ignored,
-		"SonicJSON/Unmarshal":    ignored,
-		"SonnetJSON/Marshal":     replaced,
-		"SonnetJSON/Unmarshal":   replaced,
+	// Implementations that are known to crash are permitted to crash,
+	// where the minimal crashing type is recorded in the results.
+	mayCrash := map[string]bool{
+		"GoJSON": true, // marshaling a pointer to a pointer to a map runs out of memory
 	}
-	for _, a := range arshalers {
-		t.Run(a.name+"/Marshal", func(t *testing.T) {
-			var got mode
-			switch b, err := a.marshal("\xbe\xef\xff"); {
-			case err == nil && string(b) == "\"\xbe\xef\xff\"":
-				got = ignored
-			case err == nil && string(b) == `"\ufffd\ufffd\ufffd"`:
-				got = replaced
-			case err != nil:
-				got = rejected
-			default:
-				t.Errorf("unknown mode: json.Marshal = (%s, %v)", b, err)
+
+	// check marshals and unmarshals a random value of type rt
+	// and reports any difference with JSONv1.
+	check := func(a string, marshal func(any) ([]byte, error), unmarshal func([]byte, any) error, rt *randomType, seed uint64) (kind string, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				kind, err = "panic", fmt.Errorf("%v", r)
 			}
-			if want := wantModes[a.name+"/Marshal"]; got != want {
-				t.Errorf("mode = %s, want %s", got, want)
+		}()
+		typ := rt.reflectType()
+		v := reflect.New(typ)
+		fillRandomValue(rand.New(rand.NewPCG(seed, 0)), v.Elem())
+
+		want := reflect.New(typ)
+		wantBuf := must.Get(jsonv1.Marshal(v.Interface()))
+		must.Do(jsonv1.Unmarshal(wantBuf, want.Interface()))
+
+		gotBuf, err := marshal(v.Interface())
+		if err != nil {
+			return "Marshal error", err
+		}
+		// JSONv2 intentionally differs in the semantics of the string option
+		// and the formatting of floating-point numbers,
+		// so only check whether it roundtrips.
+		if a != "JSONv2" && !equalRawValue(gotBuf, wantBuf) {
+			return "Marshal mismatch", fmt.Errorf("\n\tgot:  %s\n\twant: %s", gotBuf, wantBuf)
+		}
+		got := reflect.New(typ)
+		if err := unmarshal(gotBuf, got.Interface()); err != nil {
+			return "Unmarshal error", fmt.Errorf("%s: %v", gotBuf, err)
+		}
+		if diff := cmp.Diff(got.Interface(), want.Interface(), cmpopts.EquateEmpty()); diff != "" {
+			return "Unmarshal mismatch", fmt.Errorf("%s: (-got +want):\n%s", gotBuf, diff)
+		}
+		return "", nil
+	}
+
+	// Within the child process, check all random types starting at
+	// the requested index and report each minimal failing type.
+	// A shrunk type is identified by its position, which is the index of
+	// the random type followed by the indexes into the result of each shrink
+	// (e.g., "5/2.0"). The position of each type is printed before it is checked.
+	// If the environment specifies a position with a slash, then the type at
+	// that position crashed the previous child process, and so shrinking
+	// continues from that type.
+	if env := os.Getenv(randomRoundtripChildEnv); env != "" {
+		implName, position, _ := strings.Cut(env, "/")
+		startText, resumeText, resume := strings.Cut(position, "/")
+		start := must.Get(strconv.Atoi(startText))
+		for _, a := range arshalers {
+			if a.name != implName {
+				continue
 			}
-		})
-		t.Run(a.name+"/Unmarshal", func(t *testing.T) {
-			var got mode
-			var s string
-			switch err := a.unmarshal([]byte("\"\xbe\xef\xff\""), &s); {
-			case err == nil && s == "\xbe\xef\xff":
-				got = ignored
-			case err == nil && s == "\ufffd\ufffd\ufffd":
-				got = replaced
-			case err != nil:
-				got = rejected
-			default:
-				t.Errorf("unknown mode: json.Unmarshal = (%q, %v)", s, err)
+			marshal := a.marshal
+			if a.name == "JSONv2" {
+				// JSONv2 intentionally marshals nil slices and maps as empty
+				// and omits empty JSON values with omitempty,
+				// which does not roundtrip the same as JSONv1.
+				marshal = func(v any) ([]byte, error) {
+					return jsonv2.Marshal(v,
+						jsonv2.FormatNilSliceAsNull(true),
+						jsonv2.FormatNilMapAsNull(true),
+						jsonv1in2.OmitEmptyWithLegacyDefinition(true))
+				}
 			}
-			if want := wantModes[a.name+"/Unmarshal"]; got != want {
-				t.Errorf("mode = %s, want %s", got, want)
+			failing := func(rt *randomType, numValues int) (string, error) {
+				for seed := range uint64(numValues) {
+					if kind, err := check(a.name, marshal, a.unmarshal, rt, seed); err != nil {
+						return kind, err
+					}
+				}
+				return "", nil
+			}
+			for i := start; i < *randomTypes; i++ {
+				rt := newRandomType(rand.New(rand.NewPCG(uint64(i), 0)), 0)
+				var path []string
+				var kind string
+				var err error
+				if i == start && resume {
+					if resumeText != "" {
+						path = strings.Split(resumeText, ".")
+					}
+					for _, j := range path {
+						rt = rt.shrink()[must.Get(strconv.Atoi(j))]
+					}
+					kind, err = "crashed", errors.New("crashed")
+				} else {
+					fmt.Printf("%s%d\n", randomRoundtripChildCheckTag, i)
+					if kind, err = failing(rt, numValues); err == nil {
+						continue
+					}
+				}
+				// Shrink the type until no simpler type fails.
+				// Since the random values differ for each type,
+				// check more values to avoid missing the failure.
+				for shrunk := true; shrunk; {
+					shrunk = false
+					for j, rt2 := range rt.shrink() {
+						path2 := append(slices.Clip(path), strconv.Itoa(j))
+						fmt.Printf("%s%d/%s\n", randomRoundtripChildCheckTag, i, strings.Join(path2, "."))
+						if kind2, err2 := failing(rt2, numShrinkValues); err2 != nil {
+							rt, path, kind, err, shrunk = rt2, path2, kind2, err2, true
+							break
+						}
+					}
+				}
+				fmt.Printf("%s%q %q %q\n", randomRoundtripChildResultTag, rt.reflectType().String(), kind, err.Error())
+			}
+			fmt.Println(randomRoundtripChildDoneTag)
+		}
+		return
+	}
+
+	if testing.Short() {
+		t.Skip("skipping child processes in short mode")
+	}
+	exe := must.Get(os.Executable())
+	gotResults := make(groupedResults)
+	for _, a := range arshalers {
+		t.Run(a.name, func(t *testing.T) {
+			seen := make(map[[2]string]bool)
+			var crash []byte // why the most recent child process crashed
+			add := func(typ, kind, detail string) {
+				if kind == "crashed" {
+					detail = string(crash)
+					if !mayCrash[a.name] {
+						t.Errorf("crashed on type %s: %s", typ, detail)
+					}
+				}
+				if !seen[[2]string{typ, kind}] {
+					seen[[2]string{typ, kind}] = true
+					gotResults.add(typ, kind, a.name)
+					t.Logf("minimal failing type: %s\n%s: %s", typ, kind, detail)
+				}
+			}
+			for position := "0"; ; {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+				cmd := exec.CommandContext(ctx, exe, "-test.run=^TestRandomRoundtrip$", fmt.Sprintf("-random-types=%d", *randomTypes))
+				cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%s/%s", randomRoundtripChildEnv, a.name, position))
+				out, _ := cmd.CombinedOutput()
+				cancel()
+
+				var last string
+				var done bool
+				for _, line := range strings.Split(string(out), "\n") {
+					switch {
+					case strings.HasPrefix(line, randomRoundtripChildCheckTag):
+						last = strings.TrimPrefix(line, randomRoundtripChildCheckTag)
+					case strings.HasPrefix(line, randomRoundtripChildResultTag):
+						var typ, kind, detail string
+						must.Get(fmt.Sscanf(strings.TrimPrefix(line, randomRoundtripChildResultTag), "%q %q %q", &typ, &kind, &detail))
+						add(typ, kind, detail)
+					case line == randomRoundtripChildDoneTag:
+						done = true
+					}
+				}
+				if done {
+					break
+				}
+				if last == "" {
+					t.Fatalf("child process failed:\n%s", out)
+				}
+				// Continue shrinking from the type that crashed.
+				if crash = crashRx.Find(out); crash == nil {
+					crash = []byte("timed out")
+				}
+				if !strings.Contains(last, "/") {
+					last += "/"
+				}
+				position = last
 			}
 		})
 	}
+	if flag.Lookup("random-types").DefValue == fmt.Sprint(*randomTypes) {
+		checkResults(t, "testdata/random_roundtrip_results.json", *updateRandomRoundtripResults, gotResults)
+	}
 }
 
-type duplicateText int
+// freshTypes counts the number of types created by newFreshType.
+var freshTypes atomic.Int64
 
-func (duplicateText) MarshalText() ([]byte, error) {
-	return []byte("duplicate"), nil
+// newFreshType returns a previously unseen struct type
+// with a single field of type t, named "v" in JSON.
+func newFreshType(t reflect.Type) reflect.Type {
+	return reflect.StructOf([]reflect.StructField{{
+		Name: fmt.Sprintf("V%d", freshTypes.Add(1)),
+		Type: t,
+		Tag:  `json:"v"`,
+	}})
 }
 
-// RFC 8259 leaves handling of duplicate JSON object names as undefined.
-// RFC 7493 forbids the presence of duplicate JSON object names.
-func TestDuplicateNames(t *testing.T) {
-	wantAllowDuplicates := map[string]bool{
-		"JSONv1":       true,
-		"JSONv1in2":    true,
-		"JSONv2":       false,
-		"JSONIterator": true,
-		"SegmentJSON":  true,
-		"GoJSON":       true,
-		"SonicJSON":    true,
-		"SonnetJSON":   true,
+// concurrencyChildEnv is the environment variable that specifies
+// the JSON implementation to test within a TestConcurrency child process.
+const concurrencyChildEnv = "JSONBENCH_CONCURRENCY"
+
+// TestConcurrency hammers each implementation from many goroutines at once,
+// mixing datasets and types, and verifies that every result matches
+// the result obtained within a single goroutine
+// (modulo the order of map entries for non-deterministic implementations).
+// Freshly created types are used so that many goroutines race to populate
+// the type caches of each implementation for the same type.
+// A lighter workload is used with -short.
+// CI also runs this with -race to detect data races within each implementation.
+//
+// Since memory corruption often crashes the entire process,
+// each implementation is tested in a separate child process.
+func TestConcurrency(t *testing.T) {
+	var (
+		typesPerDataset   = 2 // number of fresh types per dataset
+		goroutinesPerType = 8 // number of goroutines that first use each fresh type
+		opsPerGoroutine   = 3
+	)
+	if testing.Short() {
+		typesPerDataset, goroutinesPerType, opsPerGoroutine = 1, 4, 2
+	}
+	// Crashes are non-deterministic, so implementations that are known
+	// to crash are permitted to either crash or pass.
+	mayCrash := map[string]bool{
+		"GoJSON": true, // marshaling a struct containing *twitterRoot corrupts memory
+	}
+	implName := os.Getenv(concurrencyChildEnv)
+	for _, a := range arshalers {
+		if implName != "" && a.name != implName {
+			continue
+		}
+		t.Run(a.name, func(t *testing.T) {
+			if implName == "" {
+				cmd := exec.Command(must.Get(os.Executable()), "-test.run=^TestConcurrency$", fmt.Sprintf("-test.short=%v", testing.Short()))
+				cmd.Env = append(os.Environ(), concurrencyChildEnv+"="+a.name)
+				out, err := cmd.CombinedOutput()
+				switch crash := crashRx.Find(out); {
+				case err == nil:
+				case crash != nil && mayCrash[a.name]:
+					t.Logf("crashed as expected: %s", crash)
+				case crash != nil:
+					t.Errorf("crashed: %s\n%s", crash, out)
+				default:
+					t.Errorf("child process failed:\n%s", out)
+				}
+				return
+			}
+
+			funcs := []struct {
+				name      string
+				marshal   func(any) ([]byte, error)
+				unmarshal func([]byte, any) error
+			}{
+				{"Marshal", a.marshal, a.unmarshal},
+				{"MarshalWrite", func(v any) ([]byte, error) {
+					bb := new(bytes.Buffer)
+					err := a.marshalWrite(bb, v)
+					return bb.Bytes(), err
+				}, func(b []byte, v any) error {
+					return a.unmarshalRead(bytes.NewReader(b), v)
+				}},
+			}
+
+			type result struct {
+				err error
+				val any
+				out []byte
+			}
+			type job struct {
+				name    string
+				data    []byte
+				newGot  func() any    // type used by the concurrent operations
+				newWant func() any    // type used for the single goroutine result
+				value   func(any) any // returns the value to compare
+			}
+			var jobs, freshJobs []*job
+			deref := func(v any) any { return reflect.ValueOf(v).Elem().Interface() }
+			for _, td := range testdata {
+				newAny := func() any { return new(any) }
+				jobs = append(jobs, &job{
+					name: td.name + "/Concrete", data: td.data,
+					newGot: td.new, newWant: td.new, value: deref,
+				}, &job{
+					name: td.name + "/Interface", data: td.data,
+					newGot: newAny, newWant: newAny, value: deref,
+				})
+				dataType := reflect.TypeOf(td.new())
+				for i := range typesPerDataset {
+					gotType, wantType := newFreshType(dataType), newFreshType(dataType)
+					freshJobs = append(freshJobs, &job{
+						name: fmt.Sprintf("%s/Fresh%d", td.name, i), data: slices.Concat([]byte(`{"v":`), td.data, []byte(`}`)),
+						newGot:  func() any { return reflect.New(gotType).Interface() },
+						newWant: func() any { return reflect.New(wantType).Interface() },
+						value:   func(v any) any { return reflect.ValueOf(v).Elem().Field(0).Interface() },
+					})
+				}
+			}
+			jobs = append(jobs, freshJobs...)
+			run := func(j *job, i int, v any) (r result) {
+				defer func() {
+					if ex := recover(); ex != nil {
+						r.err = fmt.Errorf("panic: %v", ex)
+					}
+				}()
+				if r.err = funcs[i].unmarshal(j.data, v); r.err == nil {
+					r.val = j.value(v)
+					r.out, r.err = funcs[i].marshal(v)
+				}
+				return r
+			}
+
+			// Plan the operations for each goroutine, where the first operation
+			// of every goroutine uses the fresh type for some dataset such that
+			// several goroutines race to populate the type cache for each type.
+			// Compute the results for each planned operation in a single goroutine.
+			type op struct {
+				j *job
+				i int // index into funcs
+			}
+			plans := make([][]op, goroutinesPerType*len(freshJobs))
+			want := make(map[op]result)
+			for g := range plans {
+				r := rand.New(rand.NewPCG(uint64(g), 0))
+				plans[g] = append(plans[g], op{freshJobs[g%len(freshJobs)], r.IntN(len(funcs))})
+				for len(plans[g]) < opsPerGoroutine {
+					plans[g] = append(plans[g], op{jobs[r.IntN(len(jobs))], r.IntN(len(funcs))})
+				}
+				for _, o := range plans[g] {
+					if _, ok := want[o]; !ok {
+						want[o] = run(o.j, o.i, o.j.newWant())
+					}
+				}
+			}
+
+			// Start all goroutines at once to maximize contention.
+			var mu sync.Mutex
+			failures := make(map[string]int)
+			var wg sync.WaitGroup
+			start := make(chan struct{})
+			for _, plan := range plans {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-start
+					for _, o := range plan {
+						got, want := run(o.j, o.i, o.j.newGot()), want[o]
+						var failure string
+						switch {
+						case (got.err != nil) != (want.err != nil):
+							failure = fmt.Sprintf("error = %v, want %v", got.err, want.err)
+						case got.err == nil && !reflect.DeepEqual(got.val, want.val):
+							failure = "unmarshaled value mismatch"
+						case got.err == nil && !bytes.Equal(got.out, want.out) && !equalRawValue(got.out, want.out):
+							failure = "marshaled output mismatch"
+						}
+						if failure != "" {
+							mu.Lock()
+							failures[fmt.Sprintf("%s/%s: %s", o.j.name, funcs[o.i].name, failure)]++
+							mu.Unlock()
+						}
+					}
+				}()
+			}
+			close(start)
+			wg.Wait()
+			for _, failure := range slices.Sorted(maps.Keys(failures)) {
+				t.Errorf("%s (%d times)", failure, failures[failure])
+			}
+		})
+	}
+}
+
+var updateStreamingResults = flag.Bool("update-streaming-results", false, "update the results from running the streaming test")
+
+// discardValue is a JSON value that discards its contents when unmarshaled.
+type discardValue struct{}
+
+func (*discardValue) UnmarshalJSON([]byte) error { return nil }
+
+// nestedObject is a JSON object that may be recursively nested.
+type nestedObject struct {
+	X *nestedObject `json:"x,omitempty"`
+}
+
+// TestStreaming tests whether the implementation is truly streaming,
+// meaning that encoding and decoding should not allocate any buffers
+// as large as the entire JSON value.
+//
+// For several shapes of JSON values, it measures the allocations
+// for increasing input sizes and fits the allocated bytes against the input size
+// to classify each implementation as allocating a fixed amount of memory (O(1)),
+// memory proportional to the largest JSON token (O(token)),
+// or memory proportional to the entire JSON value (O(input)).
+// The classification and the fitted slope (in bytes allocated per input byte)
+// are logged as a table for each function.
+// When unmarshaling, the Go value discards most of the input
+// so that only the allocations of the implementation itself are measured.
+func TestStreaming(t *testing.T) {
+	type input struct {
+		json    string
+		token   int        // size of the largest JSON token
+		value   any        // Go value to marshal
+		newDest func() any // Go value to unmarshal into
+	}
+	shapes := []struct {
+		name string
+		make func(r int) input // input size scales with r*r
+	}{{
+		// A long JSON array of small tokens (e.g., `[{},{},...]`).
+		name: "LongArray",
+		make: func(r int) input {
+			n := 50000 * r * r
+			return input{
+				json:    "[" + strings.TrimSuffix(strings.Repeat("{},", n), ",") + "]",
+				token:   len("{}"),
+				value:   make([]struct{}, n),
+				newDest: func() any { v := make([]struct{}, 0, n); return &v },
+			}
+		},
+	}, {
+		// A JSON array of long strings (e.g., `["aaa...","aaa...",...]`),
+		// where the size of each string only scales with r,
+		// to distinguish between O(token) and O(input).
+		name: "LongStrings",
+		make: func(r int) input {
+			n, s := 4*r, strings.Repeat("a", 16384*r)
+			return input{
+				json:    "[" + strings.TrimSuffix(strings.Repeat(strconv.Quote(s)+",", n), ",") + "]",
+				token:   len(strconv.Quote(s)),
+				value:   slices.Repeat([]string{s}, n),
+				newDest: func() any { v := make([]discardValue, 0, n); return &v },
+			}
+		},
+	}, {
+		// A deeply nested JSON object (e.g., `{"x":{"x":{...}}}`),
+		// where the depth is kept below the limit of all implementations
+		// (SonicJSON is limited to a depth of about 2k when marshaling).
+		name: "DeepNesting",
+		make: func(r int) input {
+			n := 120 * r * r
+			var v *nestedObject
+			for range n {
+				v = &nestedObject{v}
+			}
+			return input{
+				json:    strings.Repeat(`{"x":`, n) + "{}" + strings.Repeat("}", n),
+				token:   len(`"x"`),
+				value:   v,
+				newDest: func() any { return new(struct{}) },
+			}
+		},
+	}, {
+		// A JSON object with many members (e.g., `{"0":0,"1":0,...}`).
+		name: "LargeObject",
+		make: func(r int) input {
+			n := 10000 * r * r
+			m := make(map[string]int, n)
+			var bb bytes.Buffer
+			bb.WriteByte('{')
+			for i := range n {
+				if i > 0 {
+					bb.WriteByte(',')
+				}
+				k := strconv.Itoa(i)
+				m[k] = 0
+				bb.WriteString(strconv.Quote(k) + ":0")
+			}
+			bb.WriteByte('}')
+			return input{
+				json:    bb.String(),
+				token:   len(strconv.Quote(strconv.Itoa(n - 1))),
+				value:   m,
+				newDest: func() any { return new(struct{}) },
+			}
+		},
+	}}
+
+	gotResults := make(groupedResults)
+	tables := make(map[string][][]string) // function name -> markdown table
+	for _, funcName := range []string{"Marshal", "Unmarshal"} {
+		header := []string{"Implementation"}
+		for _, shape := range shapes {
+			header = append(header, shape.name)
+		}
+		tables[funcName] = [][]string{header}
+		for _, a := range arshalers {
+			tables[funcName] = append(tables[funcName], append([]string{a.name}, make([]string, len(shapes))...))
+		}
+	}
+	for s, shape := range shapes {
+		inputs := []input{shape.make(1), shape.make(2), shape.make(4)}
+		for i, a := range arshalers {
+			for _, funcName := range []string{"Marshal", "Unmarshal"} {
+				name := fmt.Sprintf("%s/%s", shape.name, funcName)
+				t.Run(fmt.Sprintf("%s/%s", a.name, name), func(t *testing.T) {
+					run := func(in input) {
+						switch funcName {
+						case "Marshal":
+							must.Do(a.marshalWrite(io.Discard, in.value))
+						case "Unmarshal":
+							must.Do(a.unmarshalRead(strings.NewReader(in.json), in.newDest()))
+						}
+					}
+					run(inputs[0]) // warm up any caches
+
+					// Fit the allocated bytes against the input size
+					// using a least squares linear regression,
+					// where the slope is the number of bytes allocated per input byte.
+					// The inputs grow by 1x, 4x, and 16x (and tokens by 1x, 2x, and 4x),
+					// so that the slope is dominated by the growth in allocations
+					// rather than by the fixed cost of the smallest input.
+					var sizes, allocs []float64
+					for _, in := range inputs {
+						// Run GC multiple times to fully clear any sync.Pools.
+						for i := 0; i < 10; i++ {
+							runtime.GC()
+						}
+
+						// Measure allocations before and after.
+						var statsBefore, statsAfter runtime.MemStats
+						runtime.ReadMemStats(&statsBefore)
+						run(in)
+						runtime.ReadMemStats(&statsAfter)
+
+						allocBytes := statsAfter.TotalAlloc - statsBefore.TotalAlloc
+						sizes = append(sizes, float64(len(in.json)))
+						allocs = append(allocs, float64(allocBytes))
+						t.Logf("%d input bytes: %d bytes allocated, %d objects allocated",
+							len(in.json), allocBytes, statsAfter.Mallocs-statsBefore.Mallocs)
+					}
+					var meanSize, meanAlloc, covariance, variance float64
+					for i := range sizes {
+						meanSize += sizes[i] / float64(len(sizes))
+						meanAlloc += allocs[i] / float64(len(allocs))
+					}
+					for i := range sizes {
+						covariance += (sizes[i] - meanSize) * (allocs[i] - meanAlloc)
+						variance += (sizes[i] - meanSize) * (sizes[i] - meanSize)
+					}
+					slope := covariance / variance
+
+					// Classify the growth in allocations across the input sizes,
+					// which is the slope multiplied by the growth in the input size:
+					//
+					//   - O(1) if allocations grow by less than 16 KiB and
+					//     less than 1/16 of the input growth (i.e., a slope under 1/16).
+					//     The absolute limit tolerates internal buffers that
+					//     grow to a fixed size, while the relative limit keeps
+					//     the classification strict for the smaller shapes.
+					//   - O(token) if the tokens grow and allocations grow by less than
+					//     1/3 of the input growth (i.e., a slope under 1/3).
+					//     Only the LongStrings shape has tokens that grow, which they do
+					//     1/4 as fast as the input, so buffering a fixed number of tokens
+					//     has a slope of about 1/4 or less.
+					//   - O(input) otherwise, since buffering the entire JSON value
+					//     has a slope of about 1 or more.
+					//
+					// In practice, the measured slopes are at most 0.01 for O(1),
+					// at most 0.2 for O(token), and at least 0.6 for O(input),
+					// so the classification is not sensitive to the exact thresholds.
+					first, last := inputs[0], inputs[len(inputs)-1]
+					inputGrowth := float64(len(last.json) - len(first.json))
+					tokenGrowth := float64(last.token - first.token)
+					allocGrowth := slope * inputGrowth
+					var result string
+					switch {
+					case allocGrowth < min(1<<14, inputGrowth/16):
+						result = "O(1)"
+					case tokenGrowth > 0 && 3*allocGrowth < inputGrowth:
+						result = "O(token)"
+					default:
+						result = "O(input)"
+					}
+					t.Logf("%0.3f bytes allocated per input byte: %s", slope, result)
+					gotResults.add(name, result, a.name)
+					tables[funcName][1+i][1+s] = fmt.Sprintf("%s (%0.2f)", result, max(slope, 0)) // a negative slope is noise
+				})
+			}
+		}
+	}
+	for _, funcName := range []string{"Marshal", "Unmarshal"} {
+		t.Logf("%s classification (slope):\n%s", funcName, markdownTable(tables[funcName]))
+	}
+	checkResults(t, "testdata/streaming_results.json", *updateStreamingResults, gotResults)
+}
+
+var updateTrailingReadResults = flag.Bool("update-trailing-read-results", false, "update the results from running the trailing read test")
+
+// countingReader counts the number of bytes read from the underlying reader.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (r *countingReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.n += n
+	return n, err
+}
+
+// TestTrailingRead tests how much each implementation reads from an io.Reader
+// when the JSON value is followed by other data, which matters for protocols
+// that place JSON in front of other data.
+// It checks whether unmarshalRead reports trailing data as an error,
+// how many bytes it consumes beyond the JSON value, and whether
+// a second Decode on the same streaming decoder resumes after the first value.
+func TestTrailingRead(t *testing.T) {
+	newDecoders := map[string]func(io.Reader) func(any) error{
+		"JSONv1":    func(r io.Reader) func(any) error { return jsonv1.NewDecoder(r).Decode },
+		"JSONv1in2": func(r io.Reader) func(any) error { return jsonv1in2.NewDecoder(r).Decode },
+		"JSONv2": func(r io.Reader) func(any) error {
+			d := jsontext.NewDecoder(r)
+			return func(v any) error { return jsonv2.UnmarshalDecode(d, v) }
+		},
+		"JSONIterator": func(r io.Reader) func(any) error { return jsoniter.NewDecoder(r).Decode },
+		"SegmentJSON":  func(r io.Reader) func(any) error { return segjson.NewDecoder(r).Decode },
+		"GoJSON":       func(r io.Reader) func(any) error { return gojson.NewDecoder(r).Decode },
+		"SonicJSON":    func(r io.Reader) func(any) error { return sonicdec.NewStreamDecoder(r).Decode },
+		"SonnetJSON":   func(r io.Reader) func(any) error { return sonnetjson.NewDecoder(r).Decode },
+	}
+
+	// Use enough binary data to exceed the read buffer of most implementations.
+	binary := make([]byte, 64<<10)
+	r := rand.New(rand.NewPCG(0, 0))
+	for i := range binary {
+		binary[i] = byte(r.Uint32())
+	}
+	inputs := []struct {
+		name   string
+		data   string
+		values []any // JSON values expected from decoding twice
+	}{
+		{"{}{}", "{}{}", []any{map[string]any{}, map[string]any{}}},
+		{"{} trailing", "{} trailing", []any{map[string]any{}}},
+		{`{}\n<binary>`, "{}\n" + string(binary), []any{map[string]any{}}},
+	}
+
+	gotResults := make(groupedResults)
+	for _, in := range inputs {
+		for _, a := range arshalers {
+			t.Run(fmt.Sprintf("%s/%s", a.name, in.name), func(t *testing.T) {
+				errorString := func(err error) string {
+					switch {
+					case err == nil:
+						return "ok"
+					case err == io.EOF:
+						return "EOF"
+					default:
+						return "error"
+					}
+				}
+
+				// Check how much a single call to unmarshalRead consumes.
+				cr := &countingReader{r: strings.NewReader(in.data)}
+				err := a.unmarshalRead(cr, new(any))
+				got := fmt.Sprintf("%s after reading %d of %d bytes", errorString(err), cr.n, len(in.data))
+				t.Logf("UnmarshalRead: %s: %v", got, err)
+				gotResults.add(in.name+"/UnmarshalRead", got, a.name)
+
+				// Check whether a second Decode resumes after the first value,
+				// where only `{}{}` contains a second JSON value.
+				// A successful Decode that produces an unexpected value
+				// is reported as a wrong value.
+				decode := newDecoders[a.name](strings.NewReader(in.data))
+				var results [2]string
+				var errs [2]error
+				for i := range results {
+					var v any
+					errs[i] = decode(&v)
+					results[i] = errorString(errs[i])
+					if errs[i] == nil && (i >= len(in.values) || !reflect.DeepEqual(v, in.values[i])) {
+						results[i] = fmt.Sprintf("wrong value %v", v)
+					}
+				}
+				got = fmt.Sprintf("%s, then %s", results[0], results[1])
+				t.Logf("Decode: %s: %v, %v", got, errs[0], errs[1])
+				gotResults.add(in.name+"/Decode", got, a.name)
+			})
+		}
+	}
+	checkResults(t, "testdata/trailing_read_results.json", *updateTrailingReadResults, gotResults)
+}
+
+// TestTrailingData tests whether unmarshaling from a []byte rejects
+// trailing data after the JSON value. Implementations that silently
+// ignore trailing data may decode a different value than an implementation
+// that rejects it, which enables request smuggling between two systems
+// that use different JSON implementations.
+func TestTrailingData(t *testing.T) {
+	inputs := []struct {
+		data  string
+		valid bool
+	}{
+		{"{} x", false},
+		{"{}{}", false},
+		{"1 2", false},
+		{"[]  \n", true}, // trailing whitespace is permitted
+		{"{}\x00", false},
+		{"{}\x00{}", false},
+	}
+	// wantIgnored lists the invalid inputs that each implementation accepts.
+	wantIgnored := map[string][]string{
+		"JSONIterator": {"{}\x00", "{}\x00{}"}, // stops parsing at a NUL byte
+		"GoJSON":       {"{}\x00", "{}\x00{}"}, // stops parsing at a NUL byte
+	}
+	for _, a := range arshalers {
+		t.Run(a.name, func(t *testing.T) {
+			var gotIgnored []string
+			for _, in := range inputs {
+				err := a.unmarshal([]byte(in.data), new(any))
+				switch {
+				case in.valid && err != nil:
+					t.Errorf("json.Unmarshal(%q) error: %v", in.data, err)
+				case !in.valid && err == nil:
+					gotIgnored = append(gotIgnored, in.data)
+				}
+			}
+			if !slices.Equal(gotIgnored, wantIgnored[a.name]) {
+				t.Errorf("ignored trailing data in %q, want %q", gotIgnored, wantIgnored[a.name])
+			}
+		})
+	}
+}
+
+var updateReaderResults = flag.Bool("update-reader-results", false, "update the results from running the adversarial reader test")
+
+// errReader is the error returned by the readers in TestAdversarialReaders.
+var errReader = errors.New("reader failure")
+
+// midValueErrorReader reads n bytes from r and then fails with errReader.
+type midValueErrorReader struct {
+	r io.Reader
+	n int
+}
+
+func (r *midValueErrorReader) Read(b []byte) (int, error) {
+	if r.n <= 0 {
+		return 0, errReader
+	}
+	n, err := r.r.Read(b[:min(len(b), r.n)])
+	r.n -= n
+	return n, err
+}
+
+// guardReader fails with os.ErrDeadlineExceeded once the deadline passes
+// so that implementations that are pathologically slow with r stop reading.
+// Zero-length reads are answered without calling r since
+// iotest.DataErrReader never returns from them while it holds buffered data.
+type guardReader struct {
+	r        io.Reader
+	deadline time.Time
+	expired  atomic.Bool
+	empty    atomic.Int64 // number of zero-length reads
+}
+
+func (r *guardReader) Read(b []byte) (int, error) {
+	if time.Now().After(r.deadline) {
+		r.expired.Store(true)
+		return 0, os.ErrDeadlineExceeded
+	}
+	if len(b) == 0 {
+		r.empty.Add(1)
+		return 0, nil
+	}
+	return r.r.Read(b)
+}
+
+// TestAdversarialReaders tests unmarshalRead with readers that
+// return data in small pieces or that fail partway through the JSON value,
+// which is common for network connections.
+// Readers that only return data in pieces must produce the same result
+// as unmarshal, while the error of a failing reader must be wrapped
+// (so that errors.Is reports it) rather than lost.
+// Implementations must never panic or hang.
+//
+// Implementations that are pathologically slow with a reader
+// are stopped once the timeout passes and recorded as "too slow".
+// The timeout is 500x the time to unmarshal from a []byte (at least 1s),
+// which leaves a wide margin on both sides since reading in pieces is
+// at most about 50x slower for most implementations, while it is over 2000x
+// slower for those that are quadratic in the number of reads.
+func TestAdversarialReaders(t *testing.T) {
+	const path = "testdata/reader_results.json"
+	readers := []struct {
+		name    string
+		wantErr error
+		new     func([]byte) io.Reader
+	}{
+		{"OneByteReader", nil, func(b []byte) io.Reader { return iotest.OneByteReader(bytes.NewReader(b)) }},
+		{"HalfReader", nil, func(b []byte) io.Reader { return iotest.HalfReader(bytes.NewReader(b)) }},
+		{"DataErrReader", nil, func(b []byte) io.Reader { return iotest.DataErrReader(bytes.NewReader(b)) }},
+		{"TimeoutReader", iotest.ErrTimeout, func(b []byte) io.Reader { return iotest.TimeoutReader(bytes.NewReader(b)) }},
+		{"MidValueErrorReader", errReader, func(b []byte) io.Reader { return &midValueErrorReader{bytes.NewReader(b), len(b) / 2} }},
+	}
+
+	gotResults := make(groupedResults)
+	for _, td := range testdata {
+		for _, a := range arshalers {
+			want := td.new()
+			must.Do(a.unmarshal(td.data, want)) // warm up any caches
+			want = td.new()
+			start := time.Now()
+			must.Do(a.unmarshal(td.data, want))
+			// Reading in pieces is slower, but it should not be drastically slower.
+			timeout := max(time.Second, 500*time.Since(start))
+
+			for _, rd := range readers {
+				name := fmt.Sprintf("%s/%s", rd.name, td.name)
+				t.Run(fmt.Sprintf("%s/%s", a.name, name), func(t *testing.T) {
+					type outcome struct {
+						err   error
+						panic any
+					}
+					got := td.new()
+					r := &guardReader{r: rd.new(td.data), deadline: time.Now().Add(timeout)}
+					done := make(chan outcome, 1)
+					go func() {
+						defer func() {
+							if ex := recover(); ex != nil {
+								done <- outcome{panic: ex}
+							}
+						}()
+						done <- outcome{err: a.unmarshalRead(r, got)}
+					}()
+
+					var result string
+					select {
+					case o := <-done:
+						switch {
+						case o.panic != nil:
+							result = "panic"
+							t.Logf("panic: %v", o.panic)
+						case r.expired.Load():
+							result = "too slow"
+							t.Logf("exceeded %v", timeout)
+						case o.err == nil && reflect.DeepEqual(got, want):
+							result = "ok"
+						case o.err == nil:
+							result = "mismatch"
+						case rd.wantErr == nil:
+							result = "spurious error"
+						case errors.Is(o.err, rd.wantErr):
+							result = "wrapped error"
+						case strings.Contains(o.err.Error(), rd.wantErr.Error()):
+							result = "unwrapped error"
+						default:
+							result = "lost error"
+						}
+						if o.err != nil {
+							t.Logf("error: %v", o.err)
+						}
+						if n := r.empty.Load(); n > 0 {
+							t.Logf("%d zero-length reads", n)
+						}
+					case <-time.After(timeout + time.Minute):
+						result = "hang"
+					}
+					gotResults.add(name, result, a.name)
+				})
+			}
+		}
+	}
+	checkResults(t, path, *updateReaderResults, gotResults)
+}
+
+var updateWriterResults = flag.Bool("update-writer-results", false, "update the results from running the failing writer test")
+
+// errWriter is the error returned by the writers in TestFailingWriters.
+var errWriter = errors.New("writer failure")
+
+// failingWriter accepts the first n bytes written to it
+// and then fails according to mode, recording every call to Write.
+// A short write without an error violates the io.Writer contract,
+// but a correct implementation still must not silently lose output.
+type failingWriter struct {
+	buf    bytes.Buffer // bytes accepted
+	n      int          // bytes to accept before failing
+	mode   failMode
+	calls  int // number of calls to Write
+	failed int // number of calls to Write that did not accept all bytes
+}
+
+type failMode int
+
+const (
+	failWithError     failMode = iota // return errWriter
+	failShortWrite                    // accept half of each write without an error
+	failErrShortWrite                 // return io.ErrShortWrite
+	failNever                         // accept all bytes
+)
+
+func (w *failingWriter) Write(b []byte) (int, error) {
+	w.calls++
+	if w.mode == failNever || w.buf.Len()+len(b) <= w.n {
+		return w.buf.Write(b)
+	}
+	w.failed++
+	switch w.mode {
+	case failWithError:
+		n, _ := w.buf.Write(b[:max(0, w.n-w.buf.Len())])
+		return n, errWriter
+	case failErrShortWrite:
+		n, _ := w.buf.Write(b[:max(0, w.n-w.buf.Len())])
+		return n, io.ErrShortWrite
+	default:
+		// Always make some progress so that implementations that retry
+		// short writes eventually finish.
+		return w.buf.Write(b[:(len(b)+1)/2])
+	}
+}
+
+// TestFailingWriters tests marshalWrite with writers that fail
+// halfway through the JSON output.
+// It records whether the error of the writer is reported,
+// how many bytes were written before the failure was noticed,
+// and whether a streaming encoder still works after the writer recovers.
+func TestFailingWriters(t *testing.T) {
+	newEncoders := map[string]func(io.Writer) func(any) error{
+		"JSONv1":    func(w io.Writer) func(any) error { return jsonv1.NewEncoder(w).Encode },
+		"JSONv1in2": func(w io.Writer) func(any) error { return jsonv1in2.NewEncoder(w).Encode },
+		"JSONv2": func(w io.Writer) func(any) error {
+			e := jsontext.NewEncoder(w)
+			return func(v any) error { return jsonv2.MarshalEncode(e, v) }
+		},
+		"JSONIterator": func(w io.Writer) func(any) error { return jsoniter.NewEncoder(w).Encode },
+		"SegmentJSON":  func(w io.Writer) func(any) error { return segjson.NewEncoder(w).Encode },
+		"GoJSON":       func(w io.Writer) func(any) error { return gojson.NewEncoder(w).Encode },
+		"SonicJSON":    func(w io.Writer) func(any) error { return sonicenc.NewStreamEncoder(w).Encode },
+		"SonnetJSON":   func(w io.Writer) func(any) error { return sonnetjson.NewEncoder(w).Encode },
+	}
+	writers := []struct {
+		name    string
+		mode    failMode
+		wantErr error
+	}{
+		{"Error", failWithError, errWriter},
+		{"ShortWrite", failShortWrite, io.ErrShortWrite},
+		{"ErrShortWrite", failErrShortWrite, io.ErrShortWrite},
+	}
+
+	gotResults := make(groupedResults)
+	for _, td := range testdata {
+		for _, a := range arshalers {
+			v := td.new()
+			must.Do(a.unmarshal(td.data, v))
+			want := must.Get(a.marshal(v))
+			// Reuse the encoder with a small value since some implementations
+			// keep a sticky error and rewrap it for every field encoded afterwards.
+			zero := td.new()
+			wantZero := must.Get(a.marshal(zero))
+
+			for _, wr := range writers {
+				name := fmt.Sprintf("%s/%s", wr.name, td.name)
+				t.Run(fmt.Sprintf("%s/%s", a.name, name), func(t *testing.T) {
+					errorString := func(err error, out []byte, want []byte) string {
+						switch {
+						case err == nil && equalRawValue(bytes.TrimSpace(out), want):
+							return "ok" // the implementation retried short writes
+						case err == nil:
+							return "no error"
+						case errors.Is(err, wr.wantErr):
+							return "error"
+						default:
+							return "other error"
+						}
+					}
+
+					w := &failingWriter{n: len(want) / 2, mode: wr.mode}
+					err := a.marshalWrite(w, v)
+					calls := "one call"
+					if w.calls > 1 {
+						calls = "multiple calls"
+					}
+					got := fmt.Sprintf("%s after writing %d%% in %s", errorString(err, w.buf.Bytes(), want), 100*w.buf.Len()/len(want), calls)
+					if w.failed > 1 {
+						got += " (kept writing after a failure)"
+					}
+					t.Logf("MarshalWrite: %s: %v", got, err)
+
+					// Check whether the encoder works again once the writer recovers.
+					w = &failingWriter{n: len(want) / 2, mode: wr.mode}
+					encode := newEncoders[a.name](w)
+					err1 := encode(v)
+					offset := w.buf.Len()
+					w.mode = failNever
+					err2 := encode(zero)
+					reuse := "not reusable"
+					if err2 == nil && equalRawValue(bytes.TrimSpace(w.buf.Bytes()[offset:]), wantZero) {
+						reuse = "reusable"
+					}
+					t.Logf("Encode: %s, then %s: %v, %v", errorString(err1, w.buf.Bytes()[:offset], want), reuse, err1, err2)
+					gotResults.add(name, got+", encoder "+reuse, a.name)
+				})
+			}
+		}
+	}
+	checkResults(t, "testdata/writer_results.json", *updateWriterResults, gotResults)
+}
+
+var checkIOPatterns = flag.Bool("check-io-patterns", false, "report the pattern of I/O calls made by each JSON implementation")
+
+// callRecorder records the size of every call to Read or Write.
+// For Read, the size is that of the buffer provided by the caller,
+// which reveals the buffering strategy regardless of how much data is available.
+type callRecorder struct {
+	r     io.Reader
+	sizes []int
+}
+
+func (c *callRecorder) Read(b []byte) (int, error) {
+	c.sizes = append(c.sizes, len(b))
+	return c.r.Read(b)
+}
+
+func (c *callRecorder) Write(b []byte) (int, error) {
+	c.sizes = append(c.sizes, len(b))
+	return len(b), nil
+}
+
+// histogram formats sizes as the number of calls in each power-of-two bucket,
+// where each bucket is labeled by its upper bound (e.g., "4KiB×3").
+func histogram(sizes []int) string {
+	var counts [64]int
+	for _, n := range sizes {
+		counts[bits.Len(uint(max(n, 1)-1))]++
+	}
+	var ss []string
+	for i, n := range counts {
+		if n > 0 {
+			var label string
+			switch size := 1 << i; {
+			case size >= 1<<20:
+				label = fmt.Sprintf("%dMiB", size>>20)
+			case size >= 1<<10:
+				label = fmt.Sprintf("%dKiB", size>>10)
+			default:
+				label = fmt.Sprintf("%dB", size)
+			}
+			ss = append(ss, fmt.Sprintf("%s×%d", label, n))
+		}
+	}
+	return strings.Join(ss, " ")
+}
+
+// TestIOPatterns reports the number and size of the calls to Write and Read
+// made by marshalWrite and unmarshalRead for each dataset.
+// When the io.Writer or io.Reader is a network connection or
+// a compression stream, each call has a cost of its own,
+// which is not apparent from the CPU time of the implementation itself.
+func TestIOPatterns(t *testing.T) {
+	if !*checkIOPatterns {
+		t.Skip("--check-io-patterns is not specified")
+	}
+	for _, td := range testdata {
+		t.Run(td.name, func(t *testing.T) {
+			var bb bytes.Buffer
+			tw := tabwriter.NewWriter(&bb, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(tw, "\nImplementation\tWrites\tWrite sizes\tReads\tRead sizes\n")
+			for _, a := range arshalers {
+				v := td.new()
+				must.Do(a.unmarshal(td.data, v))
+				w := new(callRecorder)
+				must.Do(a.marshalWrite(w, v))
+				r := &callRecorder{r: bytes.NewReader(td.data)}
+				must.Do(a.unmarshalRead(r, td.new()))
+				fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%s\n", a.name, len(w.sizes), histogram(w.sizes), len(r.sizes), histogram(r.sizes))
+			}
+			tw.Flush()
+			t.Log(bb.String())
+		})
+	}
+}
+
+// Per RFC 8259, section 8.1, JSON text must be encoded using UTF-8.
+func TestValidateUTF8(t *testing.T) {
+	type mode string
+	const (
+		ignored  mode = "ignored"  // invalid UTF-8 is ignored
+		replaced mode = "replaced" // invalid UTF-8 is replaced with utf8.RuneError
+		rejected mode = "rejected" // invalid UTF-8 is rejected
+	)
+	wantModes := map[string]mode{
+		"JSONv1/Marshal":         replaced,
+		"JSONv1/Unmarshal":       replaced,
+		"JSONv1in2/Marshal":      replaced,
+		"JSONv1in2/Unmarshal":    replaced,
+		"JSONv2/Marshal":         rejected,
+		"JSONv2/Unmarshal":       rejected,
+		"JSONIterator/Marshal":   replaced,
+		"JSONIterator/Unmarshal": ignored,
+		"SegmentJSON/Marshal":    replaced,
+		"SegmentJSON/Unmarshal":  replaced,
+		"GoJSON/Marshal":         replaced,
+		"GoJSON/Unmarshal":       ignored,
+		"SonicJSON/Marshal":      ignored,
+		"SonicJSON/Unmarshal":    ignored,
+		"SonnetJSON/Marshal":     replaced,
+		"SonnetJSON/Unmarshal":   replaced,
 	}
 	for _, a := range arshalers {
 		t.Run(a.name+"/Marshal", func(t *testing.T) {
-			_, err := a.marshal(map[duplicateText]int{0: 0, 1: 1})
-			gotAllowDuplicates := err == nil
-			if gotAllowDuplicates != wantAllowDuplicates[a.name] {
-				t.Errorf("AllowDuplicates = %v, want %v", gotAllowDuplicates, wantAllowDuplicates[a.name])
+			var got mode
+			switch b, err := a.marshal("\xbe\xef\xff"); {
+			case err == nil && string(b) == "\"\xbe\xef\xff\"":
+				got = ignored
+			case err == nil && string(b) == `"\ufffd\ufffd\ufffd"`:
+				got = replaced
+			case err != nil:
+				got = rejected
+			default:
+				t.Errorf("unknown mode: json.Marshal = (%s, %v)", b, err)
+			}
+			if want := wantModes[a.name+"/Marshal"]; got != want {
+				t.Errorf("mode = %s, want %s", got, want)
+			}
+		})
+		t.Run(a.name+"/Unmarshal", func(t *testing.T) {
+			var got mode
+			var s string
+			switch err := a.unmarshal([]byte("\"\xbe\xef\xff\""), &s); {
+			case err == nil && s == "\xbe\xef\xff":
+				got = ignored
+			case err == nil && s == "\ufffd\ufffd\ufffd":
+				got = replaced
+			case err != nil:
+				got = rejected
+			default:
+				t.Errorf("unknown mode: json.Unmarshal = (%q, %v)", s, err)
+			}
+			if want := wantModes[a.name+"/Unmarshal"]; got != want {
+				t.Errorf("mode = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+type duplicateText int
+
+func (duplicateText) MarshalText() ([]byte, error) {
+	return []byte("duplicate"), nil
+}
+
+// RFC 8259 leaves handling of duplicate JSON object names as undefined.
+// RFC 7493 forbids the presence of duplicate JSON object names.
+func TestDuplicateNames(t *testing.T) {
+	wantAllowDuplicates := map[string]bool{
+		"JSONv1":       true,
+		"JSONv1in2":    true,
+		"JSONv2":       false,
+		"JSONIterator": true,
+		"SegmentJSON":  true,
+		"GoJSON":       true,
+		"SonicJSON":    true,
+		"SonnetJSON":   true,
+	}
+	for _, a := range arshalers {
+		t.Run(a.name+"/Marshal", func(t *testing.T) {
+			_, err := a.marshal(map[duplicateText]int{0: 0, 1: 1})
+			gotAllowDuplicates := err == nil
+			if gotAllowDuplicates != wantAllowDuplicates[a.name] {
+				t.Errorf("AllowDuplicates = %v, want %v", gotAllowDuplicates, wantAllowDuplicates[a.name])
+			}
+		})
+	}
+	for _, a := range arshalers {
+		t.Run(a.name+"/Unmarshal", func(t *testing.T) {
+			var out map[string]int
+			err := a.unmarshal([]byte(`{"duplicate":0,"duplicate":1}`), &out)
+			gotAllowDuplicates := err == nil
+			if gotAllowDuplicates != wantAllowDuplicates[a.name] {
+				t.Errorf("AllowDuplicates = %v, want %v", gotAllowDuplicates, wantAllowDuplicates[a.name])
+			}
+		})
+	}
+}
+
+// Unknown JSON object members are ignored by default by most implementations,
+// but rejecting them is often desired when validating untrusted input.
+// Such an option must also apply within nested and embedded Go structs.
+func TestUnknownMembers(t *testing.T) {
+	type mode string
+	const (
+		ignored     mode = "ignored"     // unknown members are ignored
+		rejected    mode = "rejected"    // unknown members are rejected
+		unsupported mode = "unsupported" // no option to reject unknown members
+	)
+	wantModes := map[string]mode{
+		"JSONv1/Default":       ignored,
+		"JSONv1/Strict":        rejected,
+		"JSONv1in2/Default":    ignored,
+		"JSONv1in2/Strict":     rejected,
+		"JSONv2/Default":       ignored,
+		"JSONv2/Strict":        rejected,
+		"JSONIterator/Default": ignored,
+		"JSONIterator/Strict":  rejected,
+		"SegmentJSON/Default":  ignored,
+		"SegmentJSON/Strict":   rejected,
+		"GoJSON/Default":       ignored,
+		"GoJSON/Strict":        rejected,
+		"SonicJSON/Default":    ignored,
+		"SonicJSON/Strict":     rejected,
+		"SonnetJSON/Default":   ignored,
+		"SonnetJSON/Strict":    rejected,
+	}
+
+	type Embedded struct{ A int }
+	type Struct struct {
+		Embedded
+		B int
+	}
+	type StructPointer struct {
+		*Embedded
+		B int
+	}
+	cases := []struct {
+		name string
+		new  func() any
+		in   string
+	}{
+		{"TopLevel", func() any { return new(twitterStatus) }, `{"id":1,"unknown":0}`},
+		{"Nested", func() any { return new(twitterStatus) }, `{"id":1,"user":{"id":1,"unknown":0}}`},
+		{"Embedded", func() any { return new(Struct) }, `{"A":1,"B":2,"unknown":0}`},
+		{"EmbeddedPointer", func() any { return new(StructPointer) }, `{"A":1,"B":2,"unknown":0}`},
+	}
+
+	for _, a := range arshalers {
+		for _, tc := range cases {
+			t.Run(a.name+"/Default/"+tc.name, func(t *testing.T) {
+				var got mode
+				if err := a.unmarshal([]byte(tc.in), tc.new()); err == nil {
+					got = ignored
+				} else {
+					got = rejected
+				}
+				if want := wantModes[a.name+"/Default"]; got != want {
+					t.Errorf("mode = %s, want %s", got, want)
+				}
+
+				// The entry point used for strict mode must behave the same
+				// as unmarshal when the option is not set.
+				if a.unmarshalStrict != nil {
+					if err := a.unmarshalStrict([]byte(tc.in), tc.new(), false); (err == nil) != (got == ignored) {
+						t.Errorf("non-strict unmarshal error = %v, want mode %s", err, got)
+					}
+				}
+			})
+			t.Run(a.name+"/Strict/"+tc.name, func(t *testing.T) {
+				var got mode
+				switch {
+				case a.unmarshalStrict == nil:
+					got = unsupported
+				case a.unmarshalStrict([]byte(tc.in), tc.new(), true) == nil:
+					got = ignored
+				default:
+					got = rejected
+				}
+				if want := wantModes[a.name+"/Strict"]; got != want {
+					t.Errorf("mode = %s, want %s", got, want)
+				}
+
+				// Known members must still be accepted in strict mode.
+				if a.unmarshalStrict != nil {
+					in := strings.Replace(tc.in, `,"unknown":0`, "", 1)
+					if err := a.unmarshalStrict([]byte(in), tc.new(), true); err != nil {
+						t.Errorf("json.Unmarshal(%s) error: %v", in, err)
+					}
+				}
+			})
+		}
+	}
+}
+
+var updateParseSuiteResults = flag.Bool("update-parse-suite-results", false, "update the results from running the parsing test suite")
+
+// TestParseSuite tests each JSON implementation against a suite of tests
+// that it correctly parses or rejects a given JSON input.
+// This test suite comes from "Parsing JSON is a Minefield 💣".
+// See https://seriot.ch/projects/parsing_json.html.
+func TestParseSuite(t *testing.T) {
+	type results struct {
+		GotPassingWantFailing map[string][]string
+		GotFailingWantPassing map[string][]string
+		GotPassingWantEither  map[string][]string
+		GotFailingWantEither  map[string][]string
+	}
+
+	const dir = "testdata/JSONTestSuite"
+	entries := must.Get(os.ReadDir(dir))
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	gotResults := results{make(map[string][]string), make(map[string][]string), make(map[string][]string), make(map[string][]string)}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".json") || name == "results.json" {
+			continue
+		}
+		b := must.Get(os.ReadFile(filepath.Join(dir, name)))
+		name = strings.TrimSuffix(name, ".json")
+		for _, a := range arshalers {
+			prefix, suffix, _ := strings.Cut(name, "_")
+			switch err := a.unmarshal(b, new(jsontext.Value)); {
+			case prefix == "n" && err == nil:
+				gotResults.GotPassingWantFailing[suffix] = append(gotResults.GotPassingWantFailing[suffix], a.name)
+			case prefix == "y" && err != nil:
+				gotResults.GotFailingWantPassing[suffix] = append(gotResults.GotFailingWantPassing[suffix], a.name)
+			case prefix == "i" && err == nil:
+				gotResults.GotPassingWantEither[suffix] = append(gotResults.GotPassingWantEither[suffix], a.name)
+			case prefix == "i" && err != nil:
+				gotResults.GotFailingWantEither[suffix] = append(gotResults.GotFailingWantEither[suffix], a.name)
+			}
+		}
+	}
+
+	if *updateParseSuiteResults && isolatedImpl != "" {
+		t.Fatalf("cannot update results while testing only %s", isolatedImpl)
+	}
+	if *updateParseSuiteResults {
+		b := must.Get(jsonv1.Marshal(gotResults))
+		b = append(bytes.TrimSuffix(b, []byte("}")), "\n}"...) // formatting hint for hujson.Format
+		b, _ = hujson.Format(b)
+		must.Do(os.WriteFile(filepath.Join(dir, "results.json"), b, 0664))
+	} else {
+		want := must.Get(os.ReadFile(filepath.Join(dir, "results.json")))
+		var wantResults results
+		must.Do(jsonv1.Unmarshal(want, &wantResults))
+		if isolatedImpl != "" {
+			for _, m := range []map[string][]string{wantResults.GotPassingWantFailing, wantResults.GotFailingWantPassing, wantResults.GotPassingWantEither, wantResults.GotFailingWantEither} {
+				for name, impls := range m {
+					if m[name] = filterImpls(impls, isolatedImpl); m[name] == nil {
+						delete(m, name)
+					}
+				}
+			}
+		}
+		if diff := cmp.Diff(gotResults, wantResults); diff != "" {
+			t.Fatalf("mismatch (-got +want):\n%s", diff)
+		}
+	}
+}
+
+// divergence is a class of known behavior where an implementation
+// may legitimately differ from JSONv2 when unmarshaling.
+type divergence string
+
+const (
+	invalidUTF8    divergence = "UTF-8"          // input contains invalid UTF-8 or invalid surrogate pairs
+	duplicateNames divergence = "duplicates"     // input contains duplicate object names
+	trailingData   divergence = "trailing data"  // input contains data after the top-level value
+	numberHandling divergence = "numbers"        // accepts or rejects differently, but not if all numbers are replaced with 0
+	corruptNumber  divergence = "corrupt number" // decodes a different value, but not if all numbers are replaced with 0
+
+	// The following classes are determined by the first syntax error
+	// reported by JSONv2 for input that is not valid JSON.
+	invalidNumber      divergence = "invalid number"      // number has invalid syntax (e.g., "1.", "012", or "0+")
+	invalidEscape      divergence = "invalid escape"      // string contains an invalid escape sequence (e.g., `\a` or `\u12`)
+	controlCharacter   divergence = "control character"   // string contains an unescaped control character
+	unterminatedString divergence = "unterminated string" // string truncated by the end of input
+	nonStringName      divergence = "non-string name"     // object member name is not a string
+	afterWhitespace    divergence = "after whitespace"    // invalid character immediately after whitespace
+	invalidSyntax      divergence = "syntax"              // any other syntax error, which is never a known divergence
+
+	// invalidSkippedValue applies to input where the first syntax error
+	// is within a value that is not decoded into the Go value,
+	// such as the value of an unknown object member or a raw JSON value.
+	invalidSkippedValue divergence = "invalid skipped value"
+)
+
+// knownDivergences is the set of known classes of divergent behavior
+// for each implementation relative to JSONv2.
+// Any divergence outside of these classes is reported as a failure.
+var knownDivergences = map[string][]divergence{
+	"JSONv1":       {invalidUTF8, duplicateNames},
+	"JSONv1in2":    {invalidUTF8, duplicateNames},
+	"JSONIterator": {invalidUTF8, duplicateNames, trailingData, numberHandling, invalidNumber, controlCharacter, nonStringName},
+	"SegmentJSON":  {invalidUTF8, duplicateNames},
+	"GoJSON":       {invalidUTF8, duplicateNames, trailingData, invalidNumber, invalidEscape, controlCharacter, nonStringName, invalidSkippedValue},
+	"SonicJSON":    {invalidUTF8, duplicateNames, invalidNumber, invalidEscape, controlCharacter, unterminatedString},
+	"SonnetJSON":   {invalidUTF8, duplicateNames, corruptNumber, invalidEscape, afterWhitespace},
+}
+
+// knownPanics describes the known bugs in each implementation that panic,
+// where both the panic message and the input must match.
+// Any other panic is reported as a failure, even if JSONv2 rejects the input.
+var knownPanics = map[string]struct {
+	message *regexp.Regexp
+	input   func([]byte) bool
+}{
+	// Truncated input, depending on previously decoded input.
+	"GoJSON": {regexp.MustCompile(`^runtime error: index out of range \[\d+\] with length \d+$`), isTruncated},
+	// Numbers that underflow to zero (e.g., 1e-400).
+	"SonnetJSON": {regexp.MustCompile(`^runtime error: index out of range \[-\d+\]$`), hasUnderflow},
+}
+
+// isTruncated reports whether b is a prefix of a JSON value.
+func isTruncated(b []byte) bool {
+	d := jsontext.NewDecoder(bytes.NewReader(b), jsontext.AllowInvalidUTF8(true), jsontext.AllowDuplicateNames(true))
+	_, err := d.ReadValue()
+	return errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// hasUnderflow reports whether b contains a non-zero JSON number
+// that rounds to zero as a float64, up until any syntax error.
+func hasUnderflow(b []byte) bool {
+	d := jsontext.NewDecoder(bytes.NewReader(b), jsontext.AllowInvalidUTF8(true), jsontext.AllowDuplicateNames(true))
+	for {
+		tok, err := d.ReadToken()
+		if err != nil {
+			return false
+		}
+		if tok.Kind() == '0' {
+			mantissa, _, _ := strings.Cut(strings.ToLower(tok.String()), "e")
+			if tok.Float() == 0 && strings.Trim(mantissa, "-0.") != "" {
+				return true
+			}
+		}
+	}
+}
+
+// divergenceClasses reports the classes of divergent behavior
+// that may be triggered by the contents of the JSON input b.
+// It does not report numberHandling or invalidSkippedValue,
+// which cannot be determined from the input alone.
+func divergenceClasses(b []byte) (classes []divergence) {
+	d := jsontext.NewDecoder(bytes.NewReader(b), jsontext.AllowInvalidUTF8(true), jsontext.AllowDuplicateNames(true))
+	v, err := d.ReadValue()
+	v = v.Clone() // only valid until the next read call
+	if err != nil {
+		if !utf8.Valid(b) {
+			classes = append(classes, invalidUTF8)
+		}
+		return append(classes, syntaxClass(b, err))
+	}
+	if !utf8.Valid(b) || !v.IsValid(jsontext.AllowDuplicateNames(true)) {
+		classes = append(classes, invalidUTF8)
+	}
+	if !v.IsValid(jsontext.AllowInvalidUTF8(true)) {
+		classes = append(classes, duplicateNames)
+	}
+	if _, err := d.ReadToken(); err != io.EOF {
+		classes = append(classes, trailingData)
+	}
+	return classes
+}
+
+// syntaxClass reports the class of the syntax error err
+// reported by JSONv2 when decoding b.
+func syntaxClass(b []byte, err error) divergence {
+	var serr *jsontext.SyntacticError
+	if !errors.As(err, &serr) {
+		return invalidSyntax
+	}
+	msg, off := serr.Err.Error(), serr.ByteOffset
+	switch {
+	case strings.Contains(msg, "object member name must be a string"),
+		strings.Contains(msg, "at start of string") && off < int64(len(b)) && strings.IndexByte("-0123456789tfn[{", b[off]) >= 0:
+		return nonStringName
+	case strings.Contains(msg, "invalid escape sequence"), strings.Contains(msg, "invalid surrogate pair"):
+		return invalidEscape
+	case strings.Contains(msg, "expecting non-control character"):
+		return controlCharacter
+	case strings.Contains(msg, "in number"):
+		return invalidNumber
+	case errors.Is(err, io.ErrUnexpectedEOF) && len(b) > 0 && strings.IndexByte(".eE+-", b[len(b)-1]) >= 0:
+		return invalidNumber // number truncated by the end of input
+	case errors.Is(err, io.ErrUnexpectedEOF) && endsInString(b):
+		return unterminatedString
+	case 0 < off && off < int64(len(b)) && '0' <= b[off-1] && b[off-1] <= '9' && strings.IndexByte("0123456789.eE+-", b[off]) >= 0:
+		return invalidNumber // e.g., a leading zero, which is reported as an unexpected character after the number
+	case 0 < off && off < int64(len(b)) && strings.IndexByte(" \t\r\n", b[off-1]) >= 0 && strings.Contains(msg, "invalid character"):
+		return afterWhitespace
+	}
+	return invalidSyntax
+}
+
+// partialEscapeRegexp matches an escape sequence truncated by the end of input.
+var partialEscapeRegexp = regexp.MustCompile(`\\(u[0-9A-Fa-f]{0,3})?$`)
+
+// endsInString reports whether b ends within a JSON string,
+// such that terminating the string allows decoding beyond the end of b.
+// The string may end with a partial escape sequence (e.g., `\u12`).
+func endsInString(b []byte) bool {
+	candidates := [][]byte{b}
+	if loc := partialEscapeRegexp.FindIndex(b); loc != nil {
+		candidates = append(candidates, b[:loc[0]])
+	}
+	for _, b := range candidates {
+		d := jsontext.NewDecoder(bytes.NewReader(append(slices.Clip(b), '"')), jsontext.AllowInvalidUTF8(true), jsontext.AllowDuplicateNames(true))
+		_, err := d.ReadValue()
+		var serr *jsontext.SyntacticError
+		if err == nil || (errors.As(err, &serr) && serr.ByteOffset > int64(len(b))) {
+			return true
+		}
+	}
+	return false
+}
+
+// skipsInvalidValue reports whether the first syntax error in b is
+// within a value that is skipped when unmarshaling into a value of type t.
+func skipsInvalidValue(t reflect.Type, b []byte) bool {
+	d := jsontext.NewDecoder(bytes.NewReader(b), jsontext.AllowInvalidUTF8(true), jsontext.AllowDuplicateNames(true))
+	var serr *jsontext.SyntacticError
+	if _, err := d.ReadValue(); !errors.As(err, &serr) {
+		return false
+	}
+	for tok := range serr.JSONPointer.Tokens() {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		switch {
+		case t == reflect.TypeFor[jsontext.Value]():
+			return true
+		case t.Kind() == reflect.Struct:
+			if t = structField(t, tok); t == nil {
+				return true // unknown member
+			}
+		case t.Kind() == reflect.Slice, t.Kind() == reflect.Array, t.Kind() == reflect.Map:
+			t = t.Elem()
+		default:
+			return false // e.g., an interface value is always decoded
+		}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t == reflect.TypeFor[jsontext.Value]()
+}
+
+// structField returns the type of the field in struct type t
+// with the JSON name (matched case-insensitively as in JSONv1),
+// or nil if there is no such field.
+func structField(t reflect.Type, name string) reflect.Type {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		fieldName := f.Name
+		if tag != "" {
+			fieldName = tag
+		}
+		switch {
+		case tag == "-" || (!f.IsExported() && !f.Anonymous):
+		case f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct:
+			if ft := structField(f.Type, name); ft != nil {
+				return ft
+			}
+		case strings.EqualFold(fieldName, name):
+			return f.Type
+		}
+	}
+	return nil
+}
+
+// zeroNumbers returns a copy of b where every JSON number is replaced with 0.
+// Any input after a syntax error is copied verbatim.
+func zeroNumbers(b []byte) []byte {
+	var out []byte
+	var last int64
+	d := jsontext.NewDecoder(bytes.NewReader(b), jsontext.AllowInvalidUTF8(true), jsontext.AllowDuplicateNames(true))
+	for {
+		if d.PeekKind() == '0' {
+			v, err := d.ReadValue()
+			if err != nil {
+				break
+			}
+			end := d.InputOffset()
+			out = append(append(out, b[last:end-int64(len(v))]...), '0')
+			last = end
+		} else if _, err := d.ReadToken(); err != nil {
+			break
+		}
+	}
+	return append(out, b[last:]...)
+}
+
+// sampleValues returns up to n JSON values within v that are at most size bytes,
+// where the shallowest values that fit are preferred.
+func sampleValues(v jsontext.Value, size, n int) (out []jsontext.Value) {
+	switch {
+	case len(v) <= size:
+		return []jsontext.Value{v}
+	case v.Kind() != '[' && v.Kind() != '{':
+		return nil
+	}
+	d := jsontext.NewDecoder(bytes.NewReader(v))
+	must.Get(d.ReadToken())
+	for d.PeekKind() != ']' && d.PeekKind() != '}' && len(out) < n {
+		if v.Kind() == '{' {
+			must.Get(d.ReadToken())
+		}
+		out = append(out, sampleValues(must.Get(d.ReadValue()).Clone(), size, n-len(out))...)
+	}
+	return out
+}
+
+// FuzzDifferential unmarshals arbitrary input with each implementation
+// and compares the acceptance and decoded value against JSONv2.
+// Divergences are only permitted if they fall within the known classes
+// of divergent behavior for that implementation.
+func FuzzDifferential(f *testing.F) {
+	const dir = "testdata/JSONTestSuite"
+	for _, entry := range must.Get(os.ReadDir(dir)) {
+		if name := entry.Name(); strings.HasSuffix(name, ".json") && name != "results.json" {
+			f.Add(must.Get(os.ReadFile(filepath.Join(dir, name))))
+		}
+	}
+	for _, td := range testdata {
+		// The datasets are too large to fuzz efficiently,
+		// so seed with a sample of smaller values within each dataset.
+		for _, v := range sampleValues(td.data, 4<<10, 16) {
+			f.Add([]byte(v))
+		}
+	}
+
+	type typ struct {
+		name string
+		new  func() any
+	}
+	types := []typ{
+		{"RawValue", func() any { return new(jsontext.Value) }},
+		{"Interface", func() any { return new(any) }},
+	}
+	for _, td := range testdata {
+		types = append(types, typ{td.name, td.new})
+	}
+
+	// diverge reports how the named implementation diverges from JSONv2 on the input b,
+	// as one of the outcomes below along with a description.
+	// It reports the empty string if there is no divergence.
+	// A panic that is not a known bug is always a failure,
+	// regardless of what JSONv2 reports.
+	const (
+		knownPanic = "known panic"
+		accepted   = "accepted"
+		rejected   = "rejected"
+		mismatch   = "mismatch"
+	)
+	diverge := func(t *testing.T, name string, unmarshal func([]byte, any) error, tt typ, b []byte) (outcome, desc string) {
+		wantVal := tt.new()
+		wantErr := jsonv2.Unmarshal(b, wantVal)
+		gotVal := tt.new()
+		var panicked any
+		gotErr := func() (err error) {
+			defer func() {
+				if panicked = recover(); panicked != nil {
+					if p, ok := knownPanics[name]; !ok || !p.message.MatchString(fmt.Sprint(panicked)) || !p.input(b) {
+						t.Fatalf("%s/%s: panic for input %q: %v", tt.name, name, b, panicked)
+					}
+				}
+			}()
+			return unmarshal(b, gotVal)
+		}()
+		switch {
+		case panicked != nil:
+			return knownPanic, fmt.Sprint(panicked)
+		case gotErr == nil && wantErr != nil:
+			return accepted, fmt.Sprintf("accepted, but JSONv2 rejected: %v", wantErr)
+		case gotErr != nil && wantErr == nil:
+			return rejected, fmt.Sprintf("rejected, but JSONv2 accepted: %v", gotErr)
+		case gotErr == nil && !reflect.DeepEqual(gotVal, wantVal):
+			if diff := cmp.Diff(gotVal, wantVal, cmp.Comparer(equalRawValue)); diff != "" {
+				return mismatch, fmt.Sprintf("mismatch (-got +want):\n%s", diff)
+			}
+		}
+		return "", ""
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		var classes []divergence
+		var classified bool
+		for _, tt := range types {
+			for _, a := range arshalers {
+				if a.name == "JSONv2" {
+					continue // no need to test v2 with itself
+				}
+				outcome, diverged := diverge(t, a.name, a.unmarshal, tt, b)
+				switch outcome {
+				case "":
+					continue
+				case knownPanic:
+					t.Logf("%s/%s: known panic for input %q: %s", tt.name, a.name, b, diverged)
+					continue
+				}
+
+				if !classified {
+					classes, classified = divergenceClasses(b), true
+				}
+				gotClasses := slices.Clip(classes)
+				// A known panic with zeroed numbers is not agreement.
+				if zb := zeroNumbers(b); !bytes.Equal(zb, b) {
+					if zeroOutcome, _ := diverge(t, a.name, a.unmarshal, tt, zb); zeroOutcome == "" && outcome == mismatch {
+						gotClasses = append(gotClasses, corruptNumber)
+					} else if zeroOutcome == "" {
+						gotClasses = append(gotClasses, numberHandling)
+					}
+				}
+				if skipsInvalidValue(reflect.TypeOf(tt.new()), b) {
+					gotClasses = append(gotClasses, invalidSkippedValue)
+				}
+				known := false
+				for _, c := range gotClasses {
+					known = known || slices.Contains(knownDivergences[a.name], c)
+				}
+				if !known {
+					t.Errorf("%s/%s: unknown divergence %q for input %q: %s", tt.name, a.name, gotClasses, b, diverged)
+				}
+			}
+		}
+	})
+}
+
+// crashRx matches the line describing why a Go process crashed.
+var crashRx = regexp.MustCompile(`(?m)^(fatal error|panic|runtime|unexpected .*): .*$`)
+
+var (
+	checkNestingDepth          = flag.Bool("check-nesting-depth", false, "check the nesting depth supported by each JSON implementation")
+	updateNestingDepthResults  = flag.Bool("update-nesting-depth-results", false, "update the results from running the nesting depth test")
+	nestingDepthChildEnv       = "JSONBENCH_NESTING_DEPTH"
+	nestingDepthChildResultTag = "nesting-depth-result: "
+)
+
+// TestNestingDepth tests the maximum nesting depth that each JSON
+// implementation can unmarshal and how it fails beyond that depth.
+// Since an implementation may overflow the stack, which cannot be recovered,
+// each depth is unmarshaled within a child process with a timeout.
+func TestNestingDepth(t *testing.T) {
+	targets := []struct {
+		name string
+		new  func() any
+		// input returns JSON input that is nested depth levels deep.
+		input func(depth int) []byte
+	}{{
+		name: "Interface",
+		new:  func() any { return new(any) },
+		input: func(depth int) []byte {
+			return append(bytes.Repeat([]byte("["), depth), bytes.Repeat([]byte("]"), depth)...)
+		},
+	}, {
+		name: "Concrete",
+		new:  func() any { return new(golangNode) },
+		input: func(depth int) []byte {
+			// Each golangNode is an object and a "kids" array.
+			return append(bytes.Repeat([]byte(`{"kids":[`), depth/2), bytes.Repeat([]byte("]}"), depth/2)...)
+		},
+	}, {
+		name: "RawValue",
+		new:  func() any { return new(jsontext.Value) },
+		input: func(depth int) []byte {
+			return append(bytes.Repeat([]byte("["), depth), bytes.Repeat([]byte("]"), depth)...)
+		},
+	}}
+	depths := []int{1e3, 1e4, 1e5, 1e6, 1e7}
+	formatDepth := func(depth int) string { return fmt.Sprintf("1e%d", int(math.Log10(float64(depth)))) }
+
+	// Within the child process, unmarshal the requested input and
+	// report the result to the parent process.
+	if env := os.Getenv(nestingDepthChildEnv); env != "" {
+		implName, rest, _ := strings.Cut(env, "/")
+		targetName, depthText, _ := strings.Cut(rest, "/")
+		depth := must.Get(strconv.Atoi(depthText))
+		for _, a := range arshalers {
+			for _, tt := range targets {
+				if a.name == implName && tt.name == targetName {
+					if err := a.unmarshal(tt.input(depth), tt.new()); err != nil {
+						fmt.Println(nestingDepthChildResultTag + "error: " + strings.ReplaceAll(err.Error(), "\n", " "))
+					} else {
+						fmt.Println(nestingDepthChildResultTag + "ok")
+					}
+				}
+			}
+		}
+		return
+	}
+
+	if !*checkNestingDepth {
+		t.Skip("--check-nesting-depth is not specified")
+	}
+	exe := must.Get(os.Executable())
+	depthLimitRx := regexp.MustCompile(`(?i)depth|nest|too deep|recursion`)
+	gotResults := make(groupedResults)
+	for _, tt := range targets {
+		for _, a := range arshalers {
+			t.Run(path.Join(tt.name, a.name), func(t *testing.T) {
+				maxDepth := 0
+				result := ""
+				for _, depth := range depths {
+					ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+					cmd := exec.CommandContext(ctx, exe, "-test.run=^TestNestingDepth$")
+					cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%s/%s/%d", nestingDepthChildEnv, a.name, tt.name, depth))
+					out, err := cmd.CombinedOutput()
+					timedOut := ctx.Err() != nil
+					cancel()
+					_, got, ok := strings.Cut(string(out), nestingDepthChildResultTag)
+					got, _, _ = strings.Cut(got, "\n")
+					switch {
+					case timedOut:
+						result = "timed out at " + formatDepth(depth)
+						t.Logf("depth %d: timed out", depth)
+					case !ok || err != nil:
+						result = "crashed at " + formatDepth(depth)
+						t.Logf("depth %d: crashed: %v: %s", depth, err, crashRx.Find(out))
+					case got == "ok":
+						maxDepth = depth
+						continue
+					case depthLimitRx.MatchString(got):
+						result = "depth limit error at " + formatDepth(depth)
+						t.Logf("depth %d: %s", depth, got)
+					default:
+						result = "other error at " + formatDepth(depth)
+						t.Logf("depth %d: %s", depth, got)
+					}
+					break
+				}
+				if maxDepth > 0 {
+					result = strings.TrimSuffix("max "+formatDepth(maxDepth)+", "+result, ", ")
+				}
+				gotResults.add(tt.name, result, a.name)
+			})
+		}
+	}
+	checkResults(t, "testdata/nesting_depth_results.json", *updateNestingDepthResults, gotResults)
+}
+
+var updateStringOptionResults = flag.Bool("update-string-option-results", false, "update the results from running the string option test")
+
+// The `string` option quotes a Go value within a JSON string.
+// In v1, the option applies to a Go string, bool, or number,
+// but does not recursively take effect on composite Go types.
+// In v2, the option only applies to a Go number,
+// but recursively takes effect on Go numbers within a composite Go type.
+// Other implementations vary on quoting of non-numeric kinds,
+// pointers, and handling of JSON null.
+func TestStringOption(t *testing.T) {
+	i64 := int64(1)
+	pi64 := &i64
+	kinds := []struct {
+		name   string
+		value  any
+		inputs []string
+	}{
+		{"Int", int64(1), []string{`"1"`, `1`, `null`, `"null"`}},
+		{"Uint", uint64(1), []string{`"1"`, `1`, `null`, `"null"`}},
+		{"Float", float64(1.5), []string{`"1.5"`, `1.5`, `null`, `"null"`}},
+		{"Bool", true, []string{`"true"`, `true`, `null`, `"null"`}},
+		{"String", "hello", []string{`"\"hello\""`, `"hello"`, `null`, `"null"`}},
+		{"Pointer", pi64, []string{`"1"`, `1`, `null`, `"null"`}},
+		{"NilPointer", (*int64)(nil), []string{`"1"`, `1`, `null`, `"null"`}},
+		{"PointerPointer", &pi64, []string{`"1"`, `1`, `null`, `"null"`}},
+		{"Slice", []int64{1, 2}, []string{`["1","2"]`, `[1,2]`, `"[1,2]"`, `null`}},
+	}
+
+	gotResults := make(groupedResults)
+	for _, k := range kinds {
+		typ := reflect.StructOf([]reflect.StructField{{
+			Name: "V",
+			Type: reflect.TypeOf(k.value),
+			Tag:  `json:"V,string"`,
+		}})
+		for _, a := range arshalers {
+			in := reflect.New(typ)
+			in.Elem().Field(0).Set(reflect.ValueOf(k.value))
+			if b, err := a.marshal(in.Interface()); err != nil {
+				gotResults.add("Marshal/"+k.name, "error", a.name)
+			} else {
+				gotResults.add("Marshal/"+k.name, string(b), a.name)
+			}
+
+			for _, input := range k.inputs {
+				out := reflect.New(typ)
+				if err := a.unmarshal([]byte(`{"V":`+input+`}`), out.Interface()); err != nil {
+					gotResults.add("Unmarshal/"+k.name+"/"+input, "error", a.name)
+				} else {
+					gotResults.add("Unmarshal/"+k.name+"/"+input, formatValue(out.Elem().Field(0)), a.name)
+				}
+			}
+		}
+	}
+
+	checkResults(t, "testdata/string_option_results.json", *updateStringOptionResults, gotResults)
+}
+
+// groupedResults maps each test case to the set of implementations
+// that produced each particular result.
+type groupedResults map[string]map[string][]string
+
+func (r groupedResults) add(name, result, impl string) {
+	if r[name] == nil {
+		r[name] = make(map[string][]string)
+	}
+	r[name][result] = append(r[name][result], impl)
+}
+
+// only returns the results for only the specified implementation.
+func (r groupedResults) only(impl string) groupedResults {
+	out := make(groupedResults)
+	for name, results := range r {
+		for result, impls := range results {
+			for _, impl := range filterImpls(impls, impl) {
+				out.add(name, result, impl)
+			}
+		}
+	}
+	return out
+}
+
+// filterImpls returns the entries in impls that are either impl itself
+// or a mode of impl (e.g., "JSONv1/UseNumber").
+func filterImpls(impls []string, impl string) []string {
+	var out []string
+	for _, s := range impls {
+		if s == impl || strings.HasPrefix(s, impl+"/") {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// checkResults checks that the results match those stored at path.
+// If update is specified, then it updates the results stored at path.
+// When testing a single implementation in isolation mode,
+// only the results for that implementation are checked.
+func checkResults(t *testing.T, path string, update bool, gotResults groupedResults) {
+	t.Helper()
+	if update && isolatedImpl != "" {
+		t.Fatalf("cannot update %s while testing only %s", path, isolatedImpl)
+	}
+	if update {
+		b := must.Get(jsonv1.Marshal(gotResults))
+		b = append(bytes.TrimSuffix(b, []byte("}")), "\n}"...) // formatting hint for hujson.Format
+		b, _ = hujson.Format(b)
+		must.Do(os.WriteFile(path, b, 0664))
+	} else {
+		want := must.Get(os.ReadFile(path))
+		var wantResults groupedResults
+		must.Do(jsonv1.Unmarshal(want, &wantResults))
+		if isolatedImpl != "" {
+			wantResults = wantResults.only(isolatedImpl)
+		}
+		if diff := cmp.Diff(gotResults, wantResults); diff != "" {
+			t.Fatalf("mismatch (-got +want):\n%s", diff)
+		}
+	}
+}
+
+// formatValue formats v as a Go value, where pointers are dereferenced.
+func formatValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return "nil"
+		}
+		return "&" + formatValue(v.Elem())
+	case reflect.String:
+		return strconv.Quote(v.String())
+	case reflect.Struct:
+		var ss []string
+		for i := 0; i < v.NumField(); i++ {
+			ss = append(ss, v.Type().Field(i).Name+":"+formatValue(v.Field(i)))
+		}
+		return "{" + strings.Join(ss, " ") + "}"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+var updateEmbeddedFieldsResults = flag.Bool("update-embedded-fields-results", false, "update the results from running the embedded fields test")
+
+// Fields of embedded Go structs are promoted to the parent Go struct
+// according to the Go rules for visibility and dominance.
+// Implementations that aim to be drop-in replacements for v1
+// are expected to resolve JSON object names identically.
+func TestEmbeddedFields(t *testing.T) {
+	type (
+		A      struct{ X int }
+		B      struct{ X int }
+		Tagged struct {
+			X int `json:"X"`
+		}
+		Int int
+		a   struct{ X int }
+
+		EmbeddedPointer struct {
+			*A
+			Y int
+		}
+		EmbeddedNonStruct struct {
+			Int
+			Y int
+		}
+		ConflictEqualDepth struct {
+			A
+			B
+			Y int
+		}
+		ConflictTaggedWins struct {
+			A
+			Tagged
+			Y int
+		}
+		ConflictShallowerWins struct {
+			A
+			X int
+		}
+		UnexportedEmbedded struct {
+			a
+			Y int
+		}
+		UnexportedEmbeddedPointer struct {
+			*a
+			Y int
+		}
+	)
+	cases := []struct {
+		name  string
+		in    any
+		input string
+	}{
+		{"EmbeddedPointer", EmbeddedPointer{A: &A{X: 1}, Y: 2}, `{"X":1,"Y":2}`},
+		{"EmbeddedNilPointer", EmbeddedPointer{Y: 2}, `{"Y":2}`},
+		{"EmbeddedNonStruct", EmbeddedNonStruct{Int: 1, Y: 2}, `{"Int":1,"Y":2}`},
+		{"ConflictEqualDepth", ConflictEqualDepth{A: A{X: 1}, B: B{X: 2}, Y: 3}, `{"X":1,"Y":3}`},
+		{"ConflictTaggedWins", ConflictTaggedWins{A: A{X: 1}, Tagged: Tagged{X: 2}, Y: 3}, `{"X":1,"Y":3}`},
+		{"ConflictShallowerWins", ConflictShallowerWins{A: A{X: 1}, X: 2}, `{"X":1}`},
+		{"UnexportedEmbedded", UnexportedEmbedded{a: a{X: 1}, Y: 2}, `{"X":1,"Y":2}`},
+		{"UnexportedEmbeddedPointer", UnexportedEmbeddedPointer{a: &a{X: 1}, Y: 2}, `{"X":1,"Y":2}`},
+	}
+
+	// wantDeviations lists the cases where each implementation
+	// deviates from the behavior of JSONv1.
+	wantDeviations := map[string][]string{
+		"JSONv2":       {"EmbeddedNonStruct"},         // must be explicitly given a JSON name
+		"JSONIterator": {"UnexportedEmbeddedPointer"}, // sets an unexported field using unsafe
+		"SonicJSON":    {"UnexportedEmbeddedPointer"}, // sets an unexported field using unsafe
+	}
+
+	gotResults := make(groupedResults)
+	gotDeviations := make(map[string][]string)
+	for _, tc := range cases {
+		run := func(marshal func(any) ([]byte, error), unmarshal func([]byte, any) error) (results [2]string) {
+			if b, err := marshal(tc.in); err != nil {
+				results[0] = "error"
+			} else {
+				results[0] = string(b)
+			}
+			out := reflect.New(reflect.TypeOf(tc.in))
+			if err := unmarshal([]byte(tc.input), out.Interface()); err != nil {
+				results[1] = "error"
+			} else {
+				results[1] = formatValue(out.Elem())
+			}
+			return results
+		}
+		v1Results := run(jsonv1.Marshal, jsonv1.Unmarshal)
+		for _, a := range arshalers {
+			results := run(a.marshal, a.unmarshal)
+			gotResults.add("Marshal/"+tc.name, results[0], a.name)
+			gotResults.add("Unmarshal/"+tc.name, results[1], a.name)
+			if results != v1Results {
+				gotDeviations[a.name] = append(gotDeviations[a.name], tc.name)
+			}
+		}
+	}
+
+	checkResults(t, "testdata/embedded_fields_results.json", *updateEmbeddedFieldsResults, gotResults)
+	for _, a := range arshalers {
+		if diff := cmp.Diff(gotDeviations[a.name], wantDeviations[a.name]); diff != "" {
+			t.Errorf("%s deviations from JSONv1 mismatch (-got +want):\n%s", a.name, diff)
+		}
+	}
+}
+
+// The output of a MarshalJSON method should be validated.
+func TestValidateMarshalJSON(t *testing.T) {
+	type mode string
+	const (
+		ignored  mode = "ignored"  // invalid MarshalJSON output ignored
+		rejected mode = "rejected" // invalid MarshalJSON output rejected
+	)
+	wantModes := map[string]mode{
+		"JSONv1":       rejected,
+		"JSONv1in2":    rejected,
+		"JSONv2":       rejected,
+		"JSONIterator": ignored,
+		"SegmentJSON":  rejected,
+		"GoJSON":       rejected,
+		"SonicJSON":    rejected,
+		"SonnetJSON":   rejected,
+	}
+	for _, a := range arshalers {
+		t.Run(a.name, func(t *testing.T) {
+			var got mode
+			if _, err := a.marshal(jsontext.Value("<junk>")); err == nil {
+				got = ignored
+			} else {
+				got = rejected
+			}
+			if want := wantModes[a.name]; got != want {
+				t.Errorf("mode = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+// In v1, a nil Go slice or map is marshaled as a JSON null,
+// while in v2, it is marshaled as an empty JSON array or object.
+// Some implementations provide an option to switch between the two.
+func TestNilSlicesAndMaps(t *testing.T) {
+	type Struct struct {
+		NilSlice          []int
+		EmptySlice        []int
+		NilMap            map[string]int
+		EmptyMap          map[string]int
+		NilBytes          []byte
+		EmptyBytes        []byte
+		NilPointer        *[]int
+		PointerToNilSlice *[]int
+	}
+	in := Struct{
+		EmptySlice:        []int{},
+		EmptyMap:          map[string]int{},
+		EmptyBytes:        []byte{},
+		PointerToNilSlice: new([]int),
+	}
+
+	// knobs are the options to switch the representation of a nil slice or map
+	// to be the opposite of the default behavior.
+	knobs := map[string]func(any) ([]byte, error){
+		"JSONv1in2": func(v any) ([]byte, error) {
+			return jsonv2.Marshal(v, jsonv1in2.DefaultOptionsV1(), jsonv2.FormatNilSliceAsNull(false), jsonv2.FormatNilMapAsNull(false))
+		},
+		"JSONv2": func(v any) ([]byte, error) {
+			return jsonv2.Marshal(v, jsonv2.FormatNilSliceAsNull(true), jsonv2.FormatNilMapAsNull(true))
+		},
+		"SonicJSON": sonicjson.Config{NoNullSliceOrMap: true}.Froze().Marshal,
+	}
+
+	const (
+		nilAsNull  = `{"NilSlice":null,"EmptySlice":[],"NilMap":null,"EmptyMap":{},"NilBytes":null,"EmptyBytes":"","NilPointer":null,"PointerToNilSlice":null}`
+		nilAsEmpty = `{"NilSlice":[],"EmptySlice":[],"NilMap":{},"EmptyMap":{},"NilBytes":"","EmptyBytes":"","NilPointer":null,"PointerToNilSlice":[]}`
+	)
+	want := map[string]string{
+		"JSONv1/Default":       nilAsNull,
+		"JSONv1in2/Default":    nilAsNull,
+		"JSONv1in2/Option":     nilAsEmpty,
+		"JSONv2/Default":       nilAsEmpty,
+		"JSONv2/Option":        nilAsNull,
+		"JSONIterator/Default": nilAsNull,
+		"SegmentJSON/Default":  nilAsNull,
+		"GoJSON/Default":       nilAsNull,
+		"SonicJSON/Default":    nilAsNull,
+		"SonicJSON/Option":     strings.Replace(nilAsEmpty, `"NilBytes":""`, `"NilBytes":[]`, 1), // wrongly formats []byte as a JSON array
+		"SonnetJSON/Default":   nilAsNull,
+	}
+	for _, a := range arshalers {
+		t.Run(a.name+"/Default", func(t *testing.T) {
+			b, err := a.marshal(in)
+			if err != nil {
+				t.Fatalf("json.Marshal error: %v", err)
+			}
+			if got := string(b); got != want[a.name+"/Default"] {
+				t.Errorf("json.Marshal:\ngot  %s\nwant %s", got, want[a.name+"/Default"])
+			}
+		})
+		if marshal := knobs[a.name]; marshal != nil {
+			t.Run(a.name+"/Option", func(t *testing.T) {
+				b, err := marshal(in)
+				if err != nil {
+					t.Fatalf("json.Marshal error: %v", err)
+				}
+				if got := string(b); got != want[a.name+"/Option"] {
+					t.Errorf("json.Marshal:\ngot  %s\nwant %s", got, want[a.name+"/Option"])
+				}
+			})
+		}
+	}
+}
+
+// alwaysZero reports itself as zero through an IsZero method,
+// even though the underlying Go value is non-zero.
+type alwaysZero int
+
+func (alwaysZero) IsZero() bool { return true }
+
+// The `omitempty` and `omitzero` options are defined differently
+// across implementations. In v1, `omitempty` omits an empty Go value,
+// while in v2, `omitempty` omits a Go value that encodes as an empty JSON value.
+// The `omitzero` option omits a zero Go value (or one whose IsZero method
+// reports true), but is not supported by every implementation.
+func TestOmitEmptyAndZero(t *testing.T) {
+	type Struct struct{ A int }
+	type OmitEmpty struct {
+		Struct   Struct         `json:",omitempty"`
+		Slice    []int          `json:",omitempty"`
+		NilSlice []int          `json:",omitempty"`
+		Map      map[string]int `json:",omitempty"`
+		NilMap   map[string]int `json:",omitempty"`
+		Pointer  *int           `json:",omitempty"`
+		Time     time.Time      `json:",omitempty"`
+		IsZero   alwaysZero     `json:",omitempty"`
+		Int      int            `json:",omitempty"`
+		String   string         `json:",omitempty"`
+	}
+	type OmitZero struct {
+		Struct   Struct         `json:",omitzero"`
+		Slice    []int          `json:",omitzero"`
+		NilSlice []int          `json:",omitzero"`
+		Map      map[string]int `json:",omitzero"`
+		NilMap   map[string]int `json:",omitzero"`
+		Pointer  *int           `json:",omitzero"`
+		Time     time.Time      `json:",omitzero"`
+		IsZero   alwaysZero     `json:",omitzero"`
+		Int      int            `json:",omitzero"`
+		String   string         `json:",omitzero"`
+	}
+	const (
+		legacyOmitEmpty = `{"Struct":{"A":0},"Time":"0001-01-01T00:00:00Z","IsZero":1}`
+		v2OmitEmpty     = `{"Struct":{"A":0},"Time":"0001-01-01T00:00:00Z","IsZero":1,"Int":0}`
+		v2OmitZero      = `{"Slice":[],"Map":{}}`
+		ignoredOmitZero = `{"Struct":{"A":0},"Slice":[],"NilSlice":null,"Map":{},"NilMap":null,"Pointer":null,"Time":"0001-01-01T00:00:00Z","IsZero":1,"Int":0,"String":""}`
+	)
+	// JSONv1 documents support for omitzero as of Go 1.24, and SonicJSON
+	// only uses its own implementation (which ignores omitzero)
+	// on amd64 and arm64 prior to Go 1.24, and otherwise falls back to JSONv1.
+	go124 := slices.Contains(build.Default.ReleaseTags, "go1.24")
+	jsonv1OmitZero, sonicOmitZero := ignoredOmitZero, ignoredOmitZero
+	if go124 {
+		jsonv1OmitZero = v2OmitZero
+	}
+	if go124 || (runtime.GOARCH != "amd64" && runtime.GOARCH != "arm64") {
+		sonicOmitZero = jsonv1OmitZero
+	}
+	want := map[string]string{
+		"JSONv1/omitempty":       legacyOmitEmpty,
+		"JSONv1/omitzero":        jsonv1OmitZero,
+		"JSONv1in2/omitempty":    legacyOmitEmpty,
+		"JSONv1in2/omitzero":     v2OmitZero,
+		"JSONv2/omitempty":       v2OmitEmpty,
+		"JSONv2/omitzero":        v2OmitZero,
+		"JSONIterator/omitempty": legacyOmitEmpty,
+		"JSONIterator/omitzero":  ignoredOmitZero,
+		"SegmentJSON/omitempty":  legacyOmitEmpty,
+		"SegmentJSON/omitzero":   ignoredOmitZero,
+		"GoJSON/omitempty":       legacyOmitEmpty,
+		"GoJSON/omitzero":        ignoredOmitZero,
+		"SonicJSON/omitempty":    legacyOmitEmpty,
+		"SonicJSON/omitzero":     sonicOmitZero,
+		"SonnetJSON/omitempty":   legacyOmitEmpty,
+		"SonnetJSON/omitzero":    ignoredOmitZero,
+	}
+	for _, a := range arshalers {
+		for _, tc := range []struct {
+			name string
+			in   any
+		}{
+			{"omitempty", OmitEmpty{Slice: []int{}, Map: map[string]int{}, IsZero: 1}},
+			{"omitzero", OmitZero{Slice: []int{}, Map: map[string]int{}, IsZero: 1}},
+		} {
+			name := a.name + "/" + tc.name
+			t.Run(name, func(t *testing.T) {
+				b, err := a.marshal(tc.in)
+				if err != nil {
+					t.Fatalf("json.Marshal error: %v", err)
+				}
+				if got := string(b); got != want[name] {
+					t.Errorf("json.Marshal:\ngot  %s\nwant %s", got, want[name])
+				}
+			})
+		}
+	}
+}
+
+var updateErrorQualityResults = flag.Bool("update-error-quality-results", false, "update the results from running the error quality test")
+
+// Unmarshal errors should provide sufficient information to locate
+// the problem in the JSON input, such as the byte offset and
+// the JSON path to the invalid value, and the Go type involved.
+func TestErrorQuality(t *testing.T) {
+	type Struct struct {
+		A []int
+		B struct{ C string }
+		D []int8
+	}
+	cases := []struct {
+		name   string
+		in     string
+		offset [2]int   // range of offsets spanning the invalid JSON token
+		path   []string // segments of the path to the invalid JSON value
+		goType string   // Go type that the JSON value could not be unmarshaled into
+	}{
+		{"SyntaxInvalidCharacter", `{"A":[1,2,x]}`, [2]int{10, 11}, []string{"A", "2"}, ""},
+		{"SyntaxTrailingComma", `{"A":[1,2,]}`, [2]int{9, 11}, []string{"A"}, ""},
+		{"SyntaxUnexpectedEOF", `{"A":[1,2`, [2]int{9, 9}, []string{"A"}, ""},
+		{"SyntaxInvalidEscape", `{"B":{"C":"a\qb"}}`, [2]int{10, 16}, []string{"B", "C"}, ""},
+		{"TypeMismatchArrayElement", `{"A":[1,"2"]}`, [2]int{8, 11}, []string{"A", "1"}, "int"},
+		{"TypeMismatchNestedField", `{"B":{"C":true}}`, [2]int{10, 14}, []string{"B", "C"}, "string"},
+		{"TypeMismatchOverflow", `{"D":[300]}`, [2]int{6, 9}, []string{"D", "0"}, "int8"},
+	}
+
+	// Most implementations report the byte offset as an error field,
+	// while others only report it in the error message.
+	// An offset of zero is treated as missing since none of the cases
+	// are invalid at the start and the field is often left unpopulated.
+	offsetFields := []string{"Offset", "ByteOffset", "Pos"}
+	offsetPatterns := regexp.MustCompile(`(?:offset|index) (\d+)|#(\d+) byte`)
+
+	gotResults := make(groupedResults)
+	for _, tc := range cases {
+		// Construct a pattern that matches the full JSON path in either
+		// JSON Pointer (e.g., "/A/1") or dotted Go notation, where the path
+		// must follow the name of the Go struct (e.g., "Struct.A.1")
+		// so that a single segment is not matched anywhere in the message.
+		// A partial path (e.g., "Struct.A" for "/A/1") is not a match.
+		const pathEnd = `(?:$|[\s":,])`
+		pathRegexp := regexp.MustCompile(`(?:^|[\s"])` + regexp.QuoteMeta("/"+strings.Join(tc.path, "/")) + pathEnd +
+			`|\.` + regexp.QuoteMeta(strings.Join(tc.path, ".")) + pathEnd)
+		// The Go type must match exactly (e.g., "int" but not "int64" or "[]int").
+		goTypeRegexp := regexp.MustCompile(`(?:^|\s)` + regexp.QuoteMeta(tc.goType) + `(?:$|[\s:,])`)
+
+		for _, a := range arshalers {
+			err := a.unmarshal([]byte(tc.in), new(Struct))
+			if err == nil {
+				t.Errorf("%s/%s: json.Unmarshal error is nil, want non-nil", tc.name, a.name)
+				continue
+			}
+
+			offset := -1
+		unwrap:
+			for e := err; e != nil; e = errors.Unwrap(e) {
+				if v := reflect.Indirect(reflect.ValueOf(e)); v.Kind() == reflect.Struct {
+					for _, name := range offsetFields {
+						if f := v.FieldByName(name); f.IsValid() && f.CanInt() {
+							offset = int(f.Int())
+							break unwrap
+						}
+					}
+				}
 			}
-		})
-	}
-	for _, a := range arshalers {
-		t.Run(a.name+"/Unmarshal", func(t *testing.T) {
-			var out map[string]int
-			err := a.unmarshal([]byte(`{"duplicate":0,"duplicate":1}`), &out)
-			gotAllowDuplicates := err == nil
-			if gotAllowDuplicates != wantAllowDuplicates[a.name] {
-				t.Errorf("AllowDuplicates = %v, want %v", gotAllowDuplicates, wantAllowDuplicates[a.name])
+			if m := offsetPatterns.FindStringSubmatch(err.Error()); offset < 0 && m != nil {
+				offset = must.Get(strconv.Atoi(m[1] + m[2]))
 			}
-		})
+			switch {
+			case offset <= 0:
+				gotResults.add(tc.name+"/Offset", "missing", a.name)
+			case tc.offset[0] <= offset && offset <= tc.offset[1]:
+				gotResults.add(tc.name+"/Offset", "correct", a.name)
+			default:
+				gotResults.add(tc.name+"/Offset", "incorrect", a.name)
+			}
+
+			if pathRegexp.MatchString(err.Error()) {
+				gotResults.add(tc.name+"/Path", "present", a.name)
+			} else {
+				gotResults.add(tc.name+"/Path", "missing", a.name)
+			}
+
+			if tc.goType != "" {
+				if goTypeRegexp.MatchString(err.Error()) {
+					gotResults.add(tc.name+"/GoType", "present", a.name)
+				} else {
+					gotResults.add(tc.name+"/GoType", "missing", a.name)
+				}
+			}
+		}
 	}
+
+	checkResults(t, "testdata/error_quality_results.json", *updateErrorQualityResults, gotResults)
 }
 
-var updateParseSuiteResults = flag.Bool("update-parse-suite-results", false, "update the results from running the parsing test suite")
+// numberModes are options for each implementation that preserve
+// the precision of JSON numbers when unmarshaling into a Go interface.
+var numberModes = []struct {
+	name      string
+	unmarshal func([]byte, any) error
+}{
+	{"JSONv1/UseNumber", unmarshalWithDecoder(jsonv1.NewDecoder, (*jsonv1.Decoder).UseNumber)},
+	{"JSONv1in2/UseNumber", unmarshalWithDecoder(jsonv1in2.NewDecoder, (*jsonv1in2.Decoder).UseNumber)},
+	{"JSONv2/RawNumber", func(b []byte, v any) error {
+		return jsonv2.Unmarshal(b, v, jsonv2.WithUnmarshalers(
+			jsonv2.UnmarshalFromFunc(func(dec *jsontext.Decoder, v *any, _ jsonv2.Options) error {
+				if dec.PeekKind() != '0' {
+					return jsonv2.SkipFunc
+				}
+				val, err := dec.ReadValue()
+				*v = val.Clone()
+				return err
+			}),
+		))
+	}},
+	{"JSONIterator/UseNumber", jsoniter.Config{EscapeHTML: true, UseNumber: true}.Froze().Unmarshal},
+	{"SegmentJSON/UseNumber", unmarshalWithDecoder(segjson.NewDecoder, (*segjson.Decoder).UseNumber)},
+	{"GoJSON/UseNumber", unmarshalWithDecoder(gojson.NewDecoder, (*gojson.Decoder).UseNumber)},
+	{"SonicJSON/UseNumber", sonicjson.Config{UseNumber: true}.Froze().Unmarshal},
+	{"SonicJSON/UseInt64", sonicjson.Config{UseInt64: true}.Froze().Unmarshal},
+	{"SonnetJSON/UseNumber", unmarshalWithDecoder(sonnetjson.NewDecoder, (*sonnetjson.Decoder).UseNumber)},
+}
 
-// TestParseSuite tests each JSON implementation against a suite of tests
-// that it correctly parses or rejects a given JSON input.
-// This test suite comes from "Parsing JSON is a Minefield 💣".
-// See https://seriot.ch/projects/parsing_json.html.
-func TestParseSuite(t *testing.T) {
-	type results struct {
-		GotPassingWantFailing map[string][]string
-		GotFailingWantPassing map[string][]string
-		GotPassingWantEither  map[string][]string
-		GotFailingWantEither  map[string][]string
+var updateLargeNumberResults = flag.Bool("update-large-number-results", false, "update the results from running the large number test")
+
+// By default, implementations unmarshal a JSON number into a Go interface
+// as a float64, which silently loses precision for integers beyond ±2⁵³.
+// Most implementations provide an option to preserve the exact number.
+func TestLargeNumbers(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+	}{
+		{"MaxSafeInteger", "9007199254740991"},
+		{"MaxSafeIntegerPlusOne", "9007199254740992"},
+		{"MaxSafeIntegerPlusTwo", "9007199254740993"},
+		{"TwitterStatusID", "505874924095815681"},
+		{"MaxInt64", "9223372036854775807"},
+		{"MinInt64", "-9223372036854775808"},
+		{"MaxUint64", "18446744073709551615"},
+		{"LongMantissa", "3.14159265358979323846264338327950288"},
+		{"HugeExponent", "1e400"},
+		{"TinyExponent", "1e-400"},
 	}
 
-	const dir = "testdata/JSONTestSuite"
-	entries := must.Get(os.ReadDir(dir))
-	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
-	gotResults := results{make(map[string][]string), make(map[string][]string), make(map[string][]string), make(map[string][]string)}
-	for _, entry := range entries {
-		name := entry.Name()
-		if !strings.HasSuffix(name, ".json") || name == "results.json" {
-			continue
+	type mode struct {
+		name      string
+		unmarshal func([]byte, any) error
+	}
+	var modes []mode
+	for _, a := range arshalers {
+		modes = append(modes, mode{a.name + "/Default", a.unmarshal})
+		for _, m := range numberModes {
+			if strings.HasPrefix(m.name, a.name+"/") {
+				modes = append(modes, mode(m))
+			}
 		}
-		b := must.Get(os.ReadFile(filepath.Join(dir, name)))
-		name = strings.TrimSuffix(name, ".json")
-		for _, a := range arshalers {
-			prefix, suffix, _ := strings.Cut(name, "_")
-			switch err := a.unmarshal(b, new(jsontext.Value)); {
-			case prefix == "n" && err == nil:
-				gotResults.GotPassingWantFailing[suffix] = append(gotResults.GotPassingWantFailing[suffix], a.name)
-			case prefix == "y" && err != nil:
-				gotResults.GotFailingWantPassing[suffix] = append(gotResults.GotFailingWantPassing[suffix], a.name)
-			case prefix == "i" && err == nil:
-				gotResults.GotPassingWantEither[suffix] = append(gotResults.GotPassingWantEither[suffix], a.name)
-			case prefix == "i" && err != nil:
-				gotResults.GotFailingWantEither[suffix] = append(gotResults.GotFailingWantEither[suffix], a.name)
+	}
+
+	gotResults := make(groupedResults)
+	for _, tc := range cases {
+		want, _ := new(big.Rat).SetString(tc.in)
+		for _, m := range modes {
+			var v any
+			var panicked bool
+			err := func() error {
+				defer func() { panicked = recover() != nil }()
+				return m.unmarshal([]byte(tc.in), &v)
+			}()
+			switch {
+			case panicked:
+				gotResults.add(tc.name, "panic", m.name)
+				continue
+			case err != nil:
+				gotResults.add(tc.name, "error", m.name)
+				continue
+			}
+			got, ok := numberToRat(v)
+			switch {
+			case !ok:
+				t.Errorf("%s/%s: unexpected Go type %T", tc.name, m.name, v)
+			case got.Cmp(want) == 0:
+				gotResults.add(tc.name, "exact", m.name)
+			default:
+				diff := new(big.Rat).Abs(new(big.Rat).Sub(got, want))
+				gotResults.add(tc.name, "off by "+new(big.Float).SetRat(diff).Text('g', 3), m.name)
 			}
 		}
 	}
+	checkResults(t, "testdata/large_number_results.json", *updateLargeNumberResults, gotResults)
+}
 
-	if *updateParseSuiteResults {
-		b := must.Get(jsonv1.Marshal(gotResults))
-		b = append(bytes.TrimSuffix(b, []byte("}")), "\n}"...) // formatting hint for hujson.Format
-		b, _ = hujson.Format(b)
-		must.Do(os.WriteFile(filepath.Join(dir, "results.json"), b, 0664))
-	} else {
-		want := must.Get(os.ReadFile(filepath.Join(dir, "results.json")))
-		var wantResults results
-		must.Do(jsonv1.Unmarshal(want, &wantResults))
-		if diff := cmp.Diff(gotResults, wantResults); diff != "" {
-			t.Fatalf("mismatch (-got +want):\n%s", diff)
+// numberToRat converts a Go value produced by unmarshaling
+// a JSON number into a Go interface as an exact rational number.
+func numberToRat(v any) (*big.Rat, bool) {
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Float64:
+		if math.IsInf(rv.Float(), 0) || math.IsNaN(rv.Float()) {
+			return nil, false
+		}
+		return new(big.Rat).SetFloat64(rv.Float()), true
+	case reflect.Int, reflect.Int64:
+		return new(big.Rat).SetInt64(rv.Int()), true
+	case reflect.Uint, reflect.Uint64:
+		return new(big.Rat).SetUint64(rv.Uint()), true
+	case reflect.String: // e.g., json.Number
+		return new(big.Rat).SetString(rv.String())
+	case reflect.Slice: // e.g., jsontext.Value
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return new(big.Rat).SetString(string(rv.Bytes()))
 		}
 	}
+	return nil, false
 }
 
-// The output of a MarshalJSON method should be validated.
-func TestValidateMarshalJSON(t *testing.T) {
-	type mode string
-	const (
-		ignored  mode = "ignored"  // invalid MarshalJSON output ignored
-		rejected mode = "rejected" // invalid MarshalJSON output rejected
-	)
-	wantModes := map[string]mode{
-		"JSONv1":       rejected,
-		"JSONv1in2":    rejected,
-		"JSONv2":       rejected,
-		"JSONIterator": ignored,
-		"SegmentJSON":  rejected,
-		"GoJSON":       rejected,
-		"SonicJSON":    rejected,
-		"SonnetJSON":   rejected,
+// BenchmarkNumberModes benchmarks unmarshaling the TwitterStatus dataset,
+// which contains many 64-bit integer IDs, into a Go interface
+// with each of the number modes.
+func BenchmarkNumberModes(b *testing.B) {
+	var data []byte
+	for _, td := range testdata {
+		if td.name == "TwitterStatus" {
+			data = td.data
+		}
 	}
 	for _, a := range arshalers {
-		t.Run(a.name, func(t *testing.T) {
-			var got mode
-			if _, err := a.marshal(jsontext.Value("<junk>")); err == nil {
-				got = ignored
-			} else {
-				got = rejected
-			}
-			if want := wantModes[a.name]; got != want {
-				t.Errorf("mode = %s, want %s", got, want)
+		b.Run(a.name+"/Default", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				must.Do(a.unmarshal(data, new(any)))
 			}
 		})
+		for _, m := range numberModes {
+			if !strings.HasPrefix(m.name, a.name+"/") {
+				continue
+			}
+			b.Run(m.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					must.Do(m.unmarshal(data, new(any)))
+				}
+			})
+		}
 	}
 }
 
@@ -546,32 +3464,600 @@ func TestUnmarshalErrors(t *testing.T) {
 
 var checkBinarySize = flag.Bool("check-binary-size", false, "check binary sizes of each JSON implementation")
 
+// binarySizeTargets are the GOOS/GOARCH pairs that TestBinarySize builds for.
+var binarySizeTargets = []struct{ goos, goarch string }{
+	{"linux", "amd64"},
+	{"linux", "arm64"},
+	{"linux", "386"},
+	{"windows", "amd64"},
+	{"darwin", "arm64"},
+	{"js", "wasm"},
+}
+
+// writeMinimalProgram writes a main.go into dir that just links in
+// json.Marshal and json.Unmarshal from the provided package.
+func writeMinimalProgram(dir, pkgPath string) {
+	var bb bytes.Buffer
+	bb.WriteString("package main\n")
+	bb.WriteString("import json " + strconv.Quote(pkgPath) + "\n")
+	bb.WriteString("var v any\n")
+	bb.WriteString("func main() {\n")
+	bb.WriteString("v, v = json.Marshal(v)\n")
+	bb.WriteString("v = json.Unmarshal(v.([]byte), v)\n")
+	bb.WriteString("}\n")
+	must.Do(os.WriteFile(filepath.Join(dir, "main.go"), bb.Bytes(), 0664))
+}
+
+// goCommand returns a go command that runs in dir for the provided target.
+// It never accesses the network, so all modules must already be in the module cache.
+func goCommand(dir, goos, goarch string, args ...string) *exec.Cmd {
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS="+goos, "GOARCH="+goarch, "CGO_ENABLED=0", "GOPROXY=off", "GOFLAGS=-mod=readonly")
+	return cmd
+}
+
+// TestBinarySize cross-compiles a minimal program for each implementation
+// and target, and reports the binary sizes as a Markdown table.
+//
+// For ELF targets, the size is also attributed to packages using the sizes
+// in the symbol table, which is reported as a Markdown table
+// for linux/amd64 and as the largest packages for each ELF target.
+// Only ELF symbols have exact sizes: the sizes that go tool nm reports
+// for PE and Mach-O are inferred from the gaps between symbols,
+// and go tool nm cannot read wasm binaries at all.
 func TestBinarySize(t *testing.T) {
 	if !*checkBinarySize {
 		t.Skip("--check-binary-size is not specified")
 	}
 	dir := must.Get(os.MkdirTemp(must.Get(os.Getwd()), "binsize"))
 	defer os.RemoveAll(dir)
-	t.Logf("GOOS:%s GOARCH:%s", runtime.GOOS, runtime.GOARCH)
+
+	mib := func(n int64) string { return fmt.Sprintf("%0.3f MiB", float64(n)/(1<<20)) }
+	header := []string{"Implementation"}
+	for _, tg := range binarySizeTargets {
+		header = append(header, tg.goos+"/"+tg.goarch)
+	}
+	sizes := [][]string{header}
+	breakdowns := [][]string{{"Implementation", "Size", "Implementation packages", "Other modules", "Standard library", "Unattributed"}}
 	for _, a := range arshalers {
 		t.Run(a.name, func(t *testing.T) {
-			var bb bytes.Buffer
-			bb.WriteString("package main\n")
-			bb.WriteString("import json " + strconv.Quote(a.pkgPath) + "\n")
-			bb.WriteString("var v any\n")
-			bb.WriteString("func main() {\n")
-			bb.WriteString("v, v = json.Marshal(v)\n")
-			bb.WriteString("v = json.Unmarshal(v.([]byte), v)\n")
-			bb.WriteString("}\n")
-			must.Do(os.WriteFile(filepath.Join(dir, "main.go"), bb.Bytes(), 0664))
-
-			cmd := exec.Command("go", "build", "main.go")
-			cmd.Dir = dir
-			must.Do(cmd.Run())
-
-			t.Logf("size: %0.3f MiB", float64(must.Get(os.Stat(filepath.Join(dir, "main"))).Size())/(1<<20))
+			writeMinimalProgram(dir, a.pkgPath)
+			row := []string{a.name}
+			for _, tg := range binarySizeTargets {
+				target := tg.goos + "/" + tg.goarch
+				exe := filepath.Join(dir, "main."+tg.goos+"-"+tg.goarch)
+				if out, err := goCommand(dir, tg.goos, tg.goarch, "build", "-o", exe, "main.go").CombinedOutput(); err != nil {
+					t.Logf("%s: build error: %v\n%s", target, err, out)
+					row = append(row, "unsupported")
+					continue
+				}
+				size := must.Get(os.Stat(exe)).Size()
+				row = append(row, mib(size))
+
+				f, err := elf.Open(exe)
+				if err != nil {
+					continue // not an ELF binary
+				}
+				syms, err := f.Symbols()
+				f.Close()
+				if err != nil {
+					t.Errorf("%s: %v", target, err)
+					continue
+				}
+				modules := packageModules(t, goCommand(dir, tg.goos, tg.goarch, "list", "-deps", "-f", "{{.ImportPath}} {{with .Module}}{{.Path}}{{end}}", "main.go"))
+				pkgSizes := make(map[string]int64)
+				for _, sym := range syms {
+					if sym.Section != elf.SHN_UNDEF && sym.Size > 0 {
+						pkgSizes[symbolPackage(sym.Name, modules)] += int64(sym.Size)
+					}
+				}
+
+				// Group the packages by whether they belong to the module
+				// of the implementation, another module, or the standard library.
+				var own, deps, std int64
+				for pkg, n := range pkgSizes {
+					switch mod, ok := modules[pkg]; {
+					case !ok: // unattributed
+					case pkg == a.pkgPath || (mod != "" && mod == modules[a.pkgPath]):
+						own += n
+					case mod != "":
+						deps += n
+					default:
+						std += n
+					}
+				}
+				if target == "linux/amd64" {
+					breakdowns = append(breakdowns, []string{a.name, mib(size), mib(own), mib(deps), mib(std), mib(size - own - deps - std)})
+				}
+
+				pkgs := slices.Sorted(maps.Keys(pkgSizes))
+				slices.SortStableFunc(pkgs, func(x, y string) int {
+					return int(pkgSizes[y] - pkgSizes[x])
+				})
+				var bb bytes.Buffer
+				tw := tabwriter.NewWriter(&bb, 0, 0, 2, ' ', 0)
+				fmt.Fprintf(tw, "%s: %s\n\tPackage\tSize\n", target, mib(size))
+				for _, pkg := range pkgs[:min(10, len(pkgs))] {
+					n := pkgSizes[pkg]
+					if pkg == "" {
+						pkg = "(unattributed)"
+					}
+					fmt.Fprintf(tw, "\t%s\t%0.1f KiB\n", pkg, float64(n)/(1<<10))
+				}
+				tw.Flush()
+				t.Log(bb.String())
+			}
+			sizes = append(sizes, row)
+		})
+	}
+	t.Logf("binary sizes:\n%s", markdownTable(sizes))
+	t.Logf("binary size by package for linux/amd64:\n%s", markdownTable(breakdowns))
+}
+
+// packageModules runs cmd, which must be a "go list -deps" command that prints
+// the import path and module path of each package, and returns the module path
+// for each package, which is empty for packages in the standard library.
+func packageModules(t *testing.T, cmd *exec.Cmd) map[string]string {
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("go list error: %v", err)
+	}
+	modules := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		pkg, mod, _ := strings.Cut(line, " ")
+		modules[pkg] = mod
+	}
+	return modules
+}
+
+// symbolPackage returns the package path of the named linker symbol,
+// which is the longest known package that prefixes the symbol name.
+// It returns the empty string for symbols not attributed to any package
+// (e.g., the function table and type descriptors).
+func symbolPackage(name string, packages map[string]string) string {
+	name = strings.TrimPrefix(name, "type:.eq.") // equality functions belong to the package of the type
+	if i := strings.IndexByte(name, '['); i >= 0 {
+		name = name[:i] // type arguments may reference other packages
+	}
+	for i := len(name) - 1; i > 0; i-- {
+		if name[i] == '.' {
+			if _, ok := packages[name[:i]]; ok {
+				return name[:i]
+			}
+		}
+	}
+	return ""
+}
+
+// markdownTable formats the rows as a Markdown table,
+// where the first row is the header.
+func markdownTable(rows [][]string) string {
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+	var bb strings.Builder
+	writeRow := func(row []string, pad string) {
+		for i, cell := range row {
+			bb.WriteString("| " + cell + strings.Repeat(pad, widths[i]-utf8.RuneCountInString(cell)) + " ")
+		}
+		bb.WriteString("|\n")
+	}
+	for i, row := range rows {
+		writeRow(row, " ")
+		if i == 0 {
+			dashes := make([]string, len(widths))
+			writeRow(dashes, "-")
+		}
+	}
+	return bb.String()
+}
+
+var checkBuildTime = flag.Bool("check-build-time", false, "check build times of each JSON implementation")
+
+// TestBuildTime measures how long it takes to build the same program as
+// TestBinarySize from a clean build cache, how much of that is spent compiling
+// the implementation and linking, and how long it takes to compile a package
+// with many struct types that are marshaled and unmarshaled.
+// All times are for the host target and are reported as a Markdown table.
+// Builds run with -p=1 so that only one build action runs at a time,
+// where the time to compile the implementation is summed over its packages.
+// This is separate from TestBinarySize (and enabled by its own flag)
+// since a clean build of every implementation takes several minutes.
+func TestBuildTime(t *testing.T) {
+	if !*checkBuildTime {
+		t.Skip("--check-build-time is not specified")
+	}
+	dir := must.Get(os.MkdirTemp(must.Get(os.Getwd()), "buildtime"))
+	defer os.RemoveAll(dir)
+	structsDir := filepath.Join(dir, "structs")
+	must.Do(os.Mkdir(structsDir, 0775))
+
+	// build builds main.go in dir with a fresh build cache if clean is specified,
+	// and returns the wall time of the build and the build actions that ran.
+	var cache string
+	build := func(t *testing.T, dir string, clean bool) (time.Duration, []buildAction) {
+		if clean {
+			cache = must.Get(os.MkdirTemp("", "gocache"))
+			t.Cleanup(func() { os.RemoveAll(cache) })
+		}
+		graph := filepath.Join(dir, "actiongraph.json")
+		cmd := goCommand(dir, runtime.GOOS, runtime.GOARCH, "build", "-p=1", "-debug-actiongraph="+graph, "-o", os.DevNull, "main.go")
+		cmd.Env = append(cmd.Env, "GOCACHE="+cache)
+		start := time.Now()
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("build error: %v\n%s", err, out)
+		}
+		wall := time.Since(start)
+		var actions []buildAction
+		must.Do(jsonv1.Unmarshal(must.Get(os.ReadFile(graph)), &actions))
+		return wall, actions
+	}
+	seconds := func(d time.Duration) string { return fmt.Sprintf("%0.2fs", d.Seconds()) }
+
+	rows := [][]string{{"Implementation", "Clean build", "Compile implementation (summed)", "Link", fmt.Sprintf("Compile %d structs", numBuildTimeStructs)}}
+	for _, a := range arshalers {
+		t.Run(a.name, func(t *testing.T) {
+			writeMinimalProgram(dir, a.pkgPath)
+			modules := packageModules(t, goCommand(dir, runtime.GOOS, runtime.GOARCH, "list", "-deps", "-f", "{{.ImportPath}} {{with .Module}}{{.Path}}{{end}}", "main.go"))
+			wall, actions := build(t, dir, true)
+			var compile, link time.Duration
+			for _, act := range actions {
+				switch mod := modules[act.Package]; {
+				case act.Mode == "link":
+					link += act.CmdReal
+				case act.Mode == "build" && (act.Package == a.pkgPath || (mod != "" && mod == modules[a.pkgPath])):
+					compile += act.CmdReal
+				}
+			}
+
+			// The dependencies are already cached by the clean build,
+			// so only the package with the struct types is compiled.
+			writeStructsProgram(structsDir, a.pkgPath)
+			_, actions = build(t, structsDir, false)
+			var structs time.Duration
+			for _, act := range actions {
+				if act.Mode == "build" && act.Package == "command-line-arguments" {
+					structs += act.CmdReal
+				}
+			}
+			rows = append(rows, []string{a.name, seconds(wall), seconds(compile), seconds(link), seconds(structs)})
+		})
+	}
+	t.Logf("build times for %s/%s with -p=1:\n%s", runtime.GOOS, runtime.GOARCH, markdownTable(rows))
+}
+
+// buildAction is an action in the graph written by "go build -debug-actiongraph".
+type buildAction struct {
+	Mode    string        // e.g., "build" to compile a package or "link"
+	Package string        // import path of the package
+	CmdReal time.Duration // wall time of the commands run by the action
+}
+
+const numBuildTimeStructs = 300
+
+// writeStructsProgram writes a main.go into dir with many struct types,
+// each of which is marshaled and unmarshaled using the provided package.
+func writeStructsProgram(dir, pkgPath string) {
+	var bb bytes.Buffer
+	bb.WriteString("package main\n")
+	bb.WriteString("import json " + strconv.Quote(pkgPath) + "\n")
+	for i := range numBuildTimeStructs {
+		fmt.Fprintf(&bb, "type S%d struct {\n", i)
+		fmt.Fprintf(&bb, "\tBool bool `json:\"bool%d\"`\n", i)
+		fmt.Fprintf(&bb, "\tInt int64 `json:\"int%d,omitempty\"`\n", i)
+		fmt.Fprintf(&bb, "\tFloat float64 `json:\"float%d\"`\n", i)
+		fmt.Fprintf(&bb, "\tString string `json:\"string%d,omitempty\"`\n", i)
+		fmt.Fprintf(&bb, "\tSlice []string `json:\"slice%d\"`\n", i)
+		fmt.Fprintf(&bb, "\tMap map[string]int `json:\"map%d\"`\n", i)
+		if i > 0 {
+			fmt.Fprintf(&bb, "\tPrev *S%d `json:\"prev%d\"`\n", i-1, i)
+		}
+		fmt.Fprintf(&bb, "}\n")
+		fmt.Fprintf(&bb, "func roundtrip%d() error {\n", i)
+		fmt.Fprintf(&bb, "\tb, err := json.Marshal(&S%d{})\n", i)
+		fmt.Fprintf(&bb, "\tif err != nil {\n\t\treturn err\n\t}\n")
+		fmt.Fprintf(&bb, "\treturn json.Unmarshal(b, new(S%d))\n", i)
+		fmt.Fprintf(&bb, "}\n")
+	}
+	bb.WriteString("func main() {\n")
+	for i := range numBuildTimeStructs {
+		fmt.Fprintf(&bb, "\tif err := roundtrip%d(); err != nil {\n\t\tpanic(err)\n\t}\n", i)
+	}
+	bb.WriteString("}\n")
+	must.Do(os.WriteFile(filepath.Join(dir, "main.go"), bb.Bytes(), 0664))
+}
+
+var checkInitTime = flag.Bool("check-init-time", false, "check initialization cost of each JSON implementation")
+
+// initTraceRegexp matches a line printed by GODEBUG=inittrace=1.
+var initTraceRegexp = regexp.MustCompile(`^init (\S+) @\S+ ms, (\S+) ms clock, (\d+) bytes, (\d+) allocs$`)
+
+// TestInitTime runs the same program as TestBinarySize with GODEBUG=inittrace=1
+// and reports the time and memory spent initializing packages
+// before main is called as a Markdown table.
+// The median time of several runs is used for each package.
+func TestInitTime(t *testing.T) {
+	if !*checkInitTime {
+		t.Skip("--check-init-time is not specified")
+	}
+	const runs = 9
+	dir := must.Get(os.MkdirTemp(must.Get(os.Getwd()), "inittime"))
+	defer os.RemoveAll(dir)
+
+	rows := [][]string{{"Implementation", "Init time", "Init memory", "Init allocs", "Slowest package"}}
+	isStd := func(pkg string) bool { return !strings.Contains(strings.SplitN(pkg, "/", 2)[0], ".") }
+	for _, a := range arshalers {
+		t.Run(a.name, func(t *testing.T) {
+			writeMinimalProgram(dir, a.pkgPath)
+			exe := filepath.Join(dir, "main")
+			if out, err := goCommand(dir, runtime.GOOS, runtime.GOARCH, "build", "-o", exe, "main.go").CombinedOutput(); err != nil {
+				t.Fatalf("build error: %v\n%s", err, out)
+			}
+
+			type initStats struct {
+				times         []time.Duration
+				bytes, allocs int64
+			}
+			var pkgs []string
+			stats := make(map[string]*initStats)
+			for range runs {
+				// The program itself panics since it marshals a nil value,
+				// but the trace is printed before main is called.
+				cmd := exec.Command(exe)
+				cmd.Env = append(os.Environ(), "GODEBUG=inittrace=1")
+				out, _ := cmd.CombinedOutput()
+				for _, line := range strings.Split(string(out), "\n") {
+					m := initTraceRegexp.FindStringSubmatch(line)
+					if m == nil {
+						continue
+					}
+					s := stats[m[1]]
+					if s == nil {
+						pkgs = append(pkgs, m[1])
+						s = new(initStats)
+						stats[m[1]] = s
+					}
+					s.times = append(s.times, time.Duration(must.Get(strconv.ParseFloat(m[2], 64))*float64(time.Millisecond)))
+					s.bytes = must.Get(strconv.ParseInt(m[3], 10, 64))
+					s.allocs = must.Get(strconv.ParseInt(m[4], 10, 64))
+				}
+			}
+			if len(pkgs) == 0 {
+				t.Fatalf("no init trace in output")
+			}
+
+			var total time.Duration
+			var initBytes, initAllocs int64
+			median := make(map[string]time.Duration)
+			for _, pkg := range pkgs {
+				s := stats[pkg]
+				slices.Sort(s.times)
+				median[pkg] = s.times[len(s.times)/2]
+				total += median[pkg]
+				initBytes += s.bytes
+				initAllocs += s.allocs
+			}
+			slices.SortStableFunc(pkgs, func(x, y string) int { return int(median[y] - median[x]) })
+
+			var bb strings.Builder
+			tw := tabwriter.NewWriter(&bb, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(tw, "\n\tPackage\tTime\tMemory\tAllocs\n")
+			for _, pkg := range pkgs[:min(10, len(pkgs))] {
+				fmt.Fprintf(tw, "\t%s\t%0.3f ms\t%0.1f KiB\t%d\n", pkg, float64(median[pkg])/float64(time.Millisecond), float64(stats[pkg].bytes)/(1<<10), stats[pkg].allocs)
+			}
+			tw.Flush()
+			t.Log(bb.String())
+
+			// Report the slowest package of the implementation or its dependencies,
+			// ignoring the standard library, which is initialized by every program.
+			slowest := "none"
+			for _, pkg := range pkgs {
+				if pkg == a.pkgPath || !isStd(pkg) {
+					slowest = fmt.Sprintf("%s (%0.3f ms)", pkg, float64(median[pkg])/float64(time.Millisecond))
+					break
+				}
+			}
+			rows = append(rows, []string{a.name,
+				fmt.Sprintf("%0.3f ms", float64(total)/float64(time.Millisecond)),
+				fmt.Sprintf("%0.1f KiB", float64(initBytes)/(1<<10)),
+				strconv.FormatInt(initAllocs, 10),
+				slowest,
+			})
 		})
 	}
+	t.Logf("initialization cost for %s/%s:\n%s", runtime.GOOS, runtime.GOARCH, markdownTable(rows))
+}
+
+// gcStats is a snapshot of the runtime/metrics used by reportGCCost.
+type gcStats []metrics.Sample
+
+func readGCStats() gcStats {
+	s := gcStats{
+		{Name: "/cpu/classes/gc/total:cpu-seconds"},
+		{Name: "/cpu/classes/user:cpu-seconds"},
+		{Name: "/gc/cycles/total:gc-cycles"},
+		{Name: "/sched/pauses/total/gc:seconds"},
+	}
+	metrics.Read(s)
+	return s
+}
+
+// reportGCCost reports the cost of garbage collection during the benchmark loop
+// relative to the stats read before the loop as the following metrics:
+//
+//   - "gc-cpu-ns/op" is the CPU time spent by the GC (including assists).
+//   - "cpu-ns/op" is the total CPU time spent by user code and the GC.
+//     Unlike ns/op, this includes work done by background GC workers
+//     on other CPUs, so it reflects the cost to the whole machine.
+//   - "gc-cycles/op" is the number of GC cycles.
+//   - "gc-pause-p50-ns" and "gc-pause-p99-ns" are the median and
+//     99th percentile of the stop-the-world pauses due to the GC.
+//
+// The runtime only updates its CPU stats during a GC,
+// so a GC is forced after the loop to account for the most recent garbage.
+// Every metric is computed over the same window from before the loop
+// until after the forced GC, so it includes the forced GC, which collects
+// the garbage left by the loop. Since the testing package also runs a GC
+// before calling the benchmark function, the window starts and ends
+// with no uncollected garbage. The forced GC adds one cycle and its pauses
+// for the entire loop, which is negligible for a large b.N.
+//
+// Nothing is reported when profiling with -profile-dir,
+// since the forced GC would then appear in the profiles.
+func reportGCCost(b *testing.B, before gcStats) {
+	b.StopTimer()
+	if *profileDir != "" {
+		return
+	}
+	runtime.GC()
+	after := readGCStats()
+
+	n := float64(b.N)
+	gcCPU := after[0].Value.Float64() - before[0].Value.Float64()
+	userCPU := after[1].Value.Float64() - before[1].Value.Float64()
+	b.ReportMetric(gcCPU*1e9/n, "gc-cpu-ns/op")
+	b.ReportMetric((gcCPU+userCPU)*1e9/n, "cpu-ns/op")
+	b.ReportMetric(float64(after[2].Value.Uint64()-before[2].Value.Uint64())/n, "gc-cycles/op")
+
+	// Compute the percentiles of the pauses that occurred during the window,
+	// using the upper bound of each histogram bucket.
+	h0, h1 := before[3].Value.Float64Histogram(), after[3].Value.Float64Histogram()
+	var total uint64
+	counts := make([]uint64, len(h1.Counts))
+	for i := range counts {
+		counts[i] = h1.Counts[i] - h0.Counts[i]
+		total += counts[i]
+	}
+	percentile := func(p float64) float64 {
+		var sum uint64
+		for i, c := range counts {
+			sum += c
+			if total > 0 && float64(sum) >= p*float64(total) {
+				if upper := h1.Buckets[i+1]; !math.IsInf(upper, +1) {
+					return upper
+				}
+				return h1.Buckets[i]
+			}
+		}
+		return 0
+	}
+	b.ReportMetric(percentile(0.50)*1e9, "gc-pause-p50-ns")
+	b.ReportMetric(percentile(0.99)*1e9, "gc-pause-p99-ns")
+}
+
+// peakMemory caches the metrics measured by reportPeakMemory for each benchmark,
+// since the benchmark function is called again for each b.N.
+var peakMemory = make(map[*testing.B]map[string]float64)
+
+// reportPeakMemory runs f a few times after the benchmark loop and
+// reports the peak heap and RSS of the process while doing so as the
+// "peak-heap-B" and "peak-rss-B" metrics, relative to the usage beforehand.
+// These are separate measurements of a single operation at a time,
+// rather than samples taken during the timed benchmark loop.
+// They are only measured the first time the benchmark function is called
+// (since they do not depend on b.N) and reported again for each later b.N.
+//
+// Unlike B/op, which measures the total allocation,
+// this measures how much memory is live at once.
+// To prevent garbage that is not yet collected from dominating the result,
+// f runs untimed with GOGC=1 so that the heap stays close to the live heap.
+// The heap in use by objects is sampled every 100µs in the background
+// using runtime/metrics, so the peak heap is a lower bound,
+// which may miss a peak that lasts for less than the sampling interval.
+// The peak RSS is only reported on Linux, where the RSS high water mark
+// can be reset beforehand, and so it has no such limitation.
+//
+// Nothing is reported when profiling with -profile-dir,
+// since the extra operations would then appear in the profiles.
+func reportPeakMemory(b *testing.B, f func()) {
+	b.StopTimer()
+	if *profileDir != "" {
+		return
+	}
+	peak, ok := peakMemory[b]
+	if !ok {
+		peak = measurePeakMemory(f)
+		peakMemory[b] = peak
+	}
+	for unit, n := range peak {
+		b.ReportMetric(n, unit)
+	}
+}
+
+// measurePeakMemory measures the metrics reported by reportPeakMemory.
+func measurePeakMemory(f func()) map[string]float64 {
+	const runs = 3
+	defer debug.SetGCPercent(debug.SetGCPercent(1))
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(max(2, runtime.GOMAXPROCS(0)))) // so that sampling can run in parallel
+	debug.FreeOSMemory()
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	baseHeap := sample[0].Value.Uint64()
+	baseRSS, hasRSS := resetPeakRSS()
+
+	done := make(chan struct{})
+	peakHeap := make(chan uint64)
+	go func() {
+		var peak uint64
+		ticker := time.NewTicker(100 * time.Microsecond)
+		defer ticker.Stop()
+		for {
+			metrics.Read(sample)
+			peak = max(peak, sample[0].Value.Uint64())
+			select {
+			case <-ticker.C:
+			case <-done:
+				peakHeap <- peak
+				return
+			}
+		}
+	}()
+	for range runs {
+		f()
+	}
+	close(done)
+
+	peak := map[string]float64{"peak-heap-B": float64(max(<-peakHeap, baseHeap) - baseHeap)}
+	if peakRSS, ok := readProcStatus("VmHWM"); hasRSS && ok {
+		peak["peak-rss-B"] = float64(max(peakRSS, baseRSS) - baseRSS)
+	}
+	return peak
+}
+
+// resetPeakRSS resets the RSS high water mark of the process
+// and reports the current RSS.
+func resetPeakRSS() (uint64, bool) {
+	f, err := os.OpenFile("/proc/self/clear_refs", os.O_WRONLY, 0)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	if _, err := f.WriteString("5"); err != nil {
+		return 0, false
+	}
+	return readProcStatus("VmRSS")
+}
+
+// readProcStatus reads the named memory field in bytes from /proc/self/status.
+func readProcStatus(name string) (uint64, bool) {
+	b, err := os.ReadFile("/proc/self/status")
+	if err != nil {
+		return 0, false
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if value, ok := strings.CutPrefix(line, name+":"); ok {
+			n, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(value), " kB"), 10, 64)
+			return n << 10, err == nil
+		}
+	}
+	return 0, false
 }
 
 func Benchmark(b *testing.B) {
@@ -590,16 +4076,38 @@ func Benchmark(b *testing.B) {
 				must.Do(a.unmarshal(td.data, val))
 				b.Run(fmt.Sprintf("%s/%s/%s/Marshal", td.name, tt.name, a.name), func(b *testing.B) {
 					b.ReportAllocs()
+					gc := readGCStats()
 					for i := 0; i < b.N; i++ {
 						must.Get(a.marshal(val))
 					}
+					reportGCCost(b, gc)
+					reportPeakMemory(b, func() { must.Get(a.marshal(val)) })
 				})
 				b.Run(fmt.Sprintf("%s/%s/%s/Unmarshal", td.name, tt.name, a.name), func(b *testing.B) {
 					b.ReportAllocs()
+					gc := readGCStats()
 					for i := 0; i < b.N; i++ {
 						must.Do(a.unmarshal(td.data, tt.new()))
 					}
+					reportGCCost(b, gc)
+					reportPeakMemory(b, func() { must.Do(a.unmarshal(td.data, tt.new())) })
 				})
+				// Measure the cost of rejecting unknown members
+				// relative to the same entry point without the option.
+				if td.name == "TwitterStatus" && tt.name == "Concrete" {
+					for _, strict := range []bool{false, true} {
+						fn := map[bool]string{false: "UnmarshalNonStrict", true: "UnmarshalStrict"}[strict]
+						b.Run(fmt.Sprintf("%s/%s/%s/%s", td.name, tt.name, a.name, fn), func(b *testing.B) {
+							b.ReportAllocs()
+							gc := readGCStats()
+							for i := 0; i < b.N; i++ {
+								must.Do(a.unmarshalStrict(td.data, tt.new(), strict))
+							}
+							reportGCCost(b, gc)
+							reportPeakMemory(b, func() { must.Do(a.unmarshalStrict(td.data, tt.new(), strict)) })
+						})
+					}
+				}
 			}
 		}
 	}
@@ -42,22 +42,27 @@ func main() {
 	}
 
 	var tests, types, impls, funcs []string
-	runtimes := make(map[string]metric)
-	allocBytes := make(map[string]metric)
-	numAllocs := make(map[string]metric)
 	metrics := []struct {
 		name    string
+		unit    string
 		metrics map[string]metric
 	}{
-		{"Runtimes", runtimes},
-		{"AllocBytes", allocBytes},
-		{"NumAllocs", numAllocs},
+		{"Runtimes", "ns/op", make(map[string]metric)},
+		{"AllocBytes", "B/op", make(map[string]metric)},
+		{"NumAllocs", "allocs/op", make(map[string]metric)},
+		{"PeakHeap", "peak-heap-B", make(map[string]metric)},
+		{"PeakRSS", "peak-rss-B", make(map[string]metric)},
+		{"CPUTimes", "cpu-ns/op", make(map[string]metric)},
+		{"GCCPUTimes", "gc-cpu-ns/op", make(map[string]metric)},
+		{"GCCycles", "gc-cycles/op", make(map[string]metric)},
+		{"GCPausesP50", "gc-pause-p50-ns", make(map[string]metric)},
+		{"GCPausesP99", "gc-pause-p99-ns", make(map[string]metric)},
 	}
 
 	// Parse the benchmark output.
 	for _, line := range lines {
 		fields := strings.Split(line, "\t")
-		if len(fields) != 5 || !strings.HasPrefix(fields[0], "Benchmark/") {
+		if len(fields) < 5 || !strings.HasPrefix(fields[0], "Benchmark/") {
 			continue
 		}
 		name := strings.TrimPrefix(strings.TrimSuffix(strings.TrimRight(strings.TrimSpace(fields[0]), "012345789"), "-"), "Benchmark/")
@@ -70,19 +75,12 @@ func main() {
 		impls = appendIfNotExist(impls, segments[2])
 		funcs = appendIfNotExist(funcs, segments[3])
 		for _, field := range fields[1:] {
-			field = strings.TrimSpace(field)
-			switch {
-			case strings.HasSuffix(field, " ns/op"):
-				if n, err := strconv.ParseInt(strings.TrimSuffix(field, " ns/op"), 10, 64); err == nil {
-					runtimes[name] = runtimes[name].Add(n)
-				}
-			case strings.HasSuffix(field, " B/op"):
-				if n, err := strconv.ParseInt(strings.TrimSuffix(field, " B/op"), 10, 64); err == nil {
-					allocBytes[name] = allocBytes[name].Add(n)
-				}
-			case strings.HasSuffix(field, " allocs/op"):
-				if n, err := strconv.ParseInt(strings.TrimSuffix(field, " allocs/op"), 10, 64); err == nil {
-					numAllocs[name] = numAllocs[name].Add(n)
+			value, unit, _ := strings.Cut(strings.TrimSpace(field), " ")
+			for _, met := range metrics {
+				if unit == met.unit {
+					if n, err := strconv.ParseFloat(value, 64); err == nil {
+						met.metrics[name] = met.metrics[name].Add(n)
+					}
 				}
 			}
 		}
@@ -92,12 +90,23 @@ func main() {
 	for _, met := range metrics {
 		for _, fun := range funcs {
 			for _, typ := range types {
+				// Some functions are only benchmarked for a subset of tests.
+				var hasTests []string
+				for _, td := range tests {
+					if _, ok := met.metrics[fmt.Sprintf("%s/%s/%s/%s", td, typ, impls[0], fun)]; ok {
+						hasTests = append(hasTests, td)
+					}
+				}
+				if len(hasTests) == 0 {
+					continue
+				}
+
 				fmt.Printf("%s/%s/%s", met.name, fun, typ)
 				for _, imp := range impls {
 					fmt.Printf("\t%s", imp)
 				}
 				fmt.Println()
-				for _, td := range tests {
+				for _, td := range hasTests {
 					fmt.Printf("%s", td)
 					var m0 float64
 					for i, imp := range impls {
@@ -119,23 +128,23 @@ func main() {
 	}
 }
 
-type metric []int64
+type metric []float64
 
-func (r metric) Add(n int64) metric {
+func (r metric) Add(n float64) metric {
 	return append(r, n)
 }
 func (r metric) Mean() float64 {
 	var sum float64
 	for _, n := range r {
-		sum += float64(n)
+		sum += n
 	}
 	return sum / float64(len(r))
 }
 func (r metric) Median() float64 {
 	r = append(metric(nil), r...)
-	sort.Slice(r, func(i, j int) bool { return r[i] < r[j] })
+	sort.Float64s(r)
 	if len(r) > 0 {
-		return float64(r[len(r)/2])
+		return r[len(r)/2]
 	}
 	return math.NaN()
 }